@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// FormatAddress собирает адрес из частей для отображения: "Страна, индекс, город, улица".
+// Пустые части пропускаются.
+func (p Parcel) FormatAddress() string {
+	parts := make([]string, 0, 4)
+	for _, part := range []string{p.Country, p.PostalCode, p.City, p.Street} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// GetByPostalCode возвращает посылки с почтовым индексом code
+func (s ParcelStore) GetByPostalCode(code string) (res []Parcel, err error) {
+	span := s.startSpan("GetByPostalCode")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels("WHERE postal_code = ? AND deleted_at IS NULL AND status <> ? ORDER BY number", code, ParcelStatusDraft)
+}
+
+// GetByCountry возвращает посылки в страну country
+func (s ParcelStore) GetByCountry(country string) (res []Parcel, err error) {
+	span := s.startSpan("GetByCountry")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels("WHERE country = ? AND deleted_at IS NULL AND status <> ? ORDER BY number", country, ParcelStatusDraft)
+}
+
+// AddressCount адрес доставки с количеством посылок и разных клиентов, отправляющих на него
+type AddressCount struct {
+	Address string
+	Parcels int
+	Clients int
+}
+
+// TopAddresses возвращает limit адресов, на которые приходит больше всего посылок, по убыванию их количества.
+// Много посылок от разных клиентов на один адрес — повод проверить его на злоупотребления.
+// Пустой адрес, адрес-заглушка, черновики и удалённые посылки не учитываются.
+func (s ParcelStore) TopAddresses(limit int) (res []AddressCount, err error) {
+	span := s.startSpan("TopAddresses")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query(`SELECT address, COUNT(*), COUNT(DISTINCT client) FROM parcel
+		WHERE address <> '' AND address <> ? AND status <> ? AND deleted_at IS NULL
+		GROUP BY address ORDER BY COUNT(*) DESC, COUNT(DISTINCT client) DESC, address LIMIT ?`,
+		s.cfg.AddressPlaceholder, ParcelStatusDraft, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c AddressCount
+		if err := rows.Scan(&c.Address, &c.Parcels, &c.Clients); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
+// CountDistinctAddressesByClient возвращает, на сколько разных адресов отправляет посылки клиент.
+// Учитываются те же посылки и адреса, что в TopAddresses.
+func (s ParcelStore) CountDistinctAddressesByClient(client int64) (n int, err error) {
+	span := s.startSpan("CountDistinctAddressesByClient", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	err = s.read.QueryRow(`SELECT COUNT(DISTINCT address) FROM parcel
+		WHERE client = ? AND address <> '' AND address <> ? AND status <> ? AND deleted_at IS NULL`,
+		client, s.cfg.AddressPlaceholder, ParcelStatusDraft).Scan(&n)
+
+	return n, err
+}
+
+// CountDistinctAddresses возвращает количество разных адресов для всех клиентов одним запросом,
+// как CountDistinctAddressesByClient. Клиентов без подходящих посылок в результате нет.
+func (s ParcelStore) CountDistinctAddresses() (counts map[int64]int, err error) {
+	span := s.startSpan("CountDistinctAddresses")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query(`SELECT client, COUNT(DISTINCT address) FROM parcel
+		WHERE address <> '' AND address <> ? AND status <> ? AND deleted_at IS NULL
+		GROUP BY client`,
+		s.cfg.AddressPlaceholder, ParcelStatusDraft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts = make(map[int64]int)
+	for rows.Next() {
+		var client int64
+		var n int
+		if err := rows.Scan(&client, &n); err != nil {
+			return nil, err
+		}
+		counts[client] = n
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStructuredAddress проверяет адрес по частям и выборки по индексу и стране
+func TestStructuredAddress(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.Address = ""
+	parcel.AddressSet = false
+	parcel.Street = "ул. Колотушкина, д. 5"
+	parcel.City = "Псков"
+	parcel.PostalCode = fmt.Sprintf("%06d", randRange.Intn(1_000_000))
+	parcel.Country = fmt.Sprintf("Страна %d", randRange.Intn(10_000_000))
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, parcel.Country+", "+parcel.PostalCode+", Псков, ул. Колотушкина, д. 5", stored.Address)
+	require.Equal(t, stored.Address, stored.FormatAddress())
+	require.Equal(t, parcel.Street, stored.Street)
+	require.Equal(t, parcel.PostalCode, stored.PostalCode)
+
+	found, err := store.GetByPostalCode(parcel.PostalCode)
+	require.NoError(t, err)
+	require.Contains(t, found, stored)
+
+	found, err = store.GetByCountry(parcel.Country)
+	require.NoError(t, err)
+	require.Equal(t, []Parcel{stored}, found)
+
+	require.Equal(t, "Псков, ул. Колотушкина, д. 5", Parcel{City: "Псков", Street: "ул. Колотушкина, д. 5"}.FormatAddress())
+}
+
+// TestTopAddresses проверяет подсчёт посылок и клиентов по адресам
+func TestTopAddresses(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	address := fmt.Sprintf("Казань, ул. Баумана, %d", randRange.Intn(10_000_000))
+	client := randRange.Int63n(10_000_000)
+
+	// add
+	for i := 0; i < 10; i++ {
+		parcel := getTestParcel()
+		parcel.Address = address
+		parcel.Client = client + int64(i%3)
+		_, err := store.Add(parcel)
+		require.NoError(t, err)
+	}
+
+	// check
+	top, err := store.TopAddresses(5)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(top), 5)
+	require.Contains(t, top, AddressCount{Address: address, Parcels: 10, Clients: 3})
+	for i := 1; i < len(top); i++ {
+		require.GreaterOrEqual(t, top[i-1].Parcels, top[i].Parcels)
+	}
+}
+
+// TestCountDistinctAddresses проверяет подсчёт разных адресов клиента
+func TestCountDistinctAddresses(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	client := randRange.Int63n(10_000_000)
+
+	// add
+	for _, address := range []string{"a", "b", "a", "c"} {
+		parcel := getTestParcel()
+		parcel.Client = client
+		parcel.Address = address
+		_, err := store.Add(parcel)
+		require.NoError(t, err)
+	}
+
+	// check
+	n, err := store.CountDistinctAddressesByClient(client)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	n, err = store.CountDistinctAddressesByClient(-1)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	counts, err := store.CountDistinctAddresses()
+	require.NoError(t, err)
+	require.Equal(t, 3, counts[client])
+	require.NotContains(t, counts, int64(-1))
+}
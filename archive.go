@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Посылки, закончившие путь, можно перенести из parcel в архивную таблицу parcel_archive
+// с теми же колонками parcelColumns и временем переноса archived_at. История статусов,
+// попытки доставки, метки и журнал аудита остаются на месте под тем же номером.
+// Новую колонку parcel нужно добавлять и в parcel_archive.
+
+// ErrParcelNotArchivable архивировать можно только посылку в конечном статусе
+var ErrParcelNotArchivable = errors.New("parcel cannot be archived in its status")
+
+// Archive переносит посылку в конечном статусе (delivered, lost, returned) в parcel_archive.
+// После этого Get её не находит, а GetAnywhere находит в архиве.
+func (s ParcelStore) Archive(number int64) (err error) {
+	span := s.startSpan("Archive", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("archive", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.Archive(number)
+		})
+		return err
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		p, err := tx.GetForUpdate(number)
+		if err != nil {
+			return err
+		}
+		if !isTerminal(p.Status) {
+			return fmt.Errorf("%w: %s", ErrParcelNotArchivable, p.Status)
+		}
+		if p.OnHold {
+			return ErrParcelOnHold
+		}
+
+		if _, err := tx.db.Exec("INSERT INTO parcel_archive ("+parcelColumns+", archived_at) SELECT "+parcelColumns+", ? FROM parcel WHERE number = ?",
+			formatTime(tx.cfg.Now()), number); err != nil {
+			return err
+		}
+		_, err = tx.db.Exec("DELETE FROM parcel WHERE number = ?", number)
+		return err
+	})
+}
+
+// GetAnywhere ищет посылку сначала в parcel, затем в parcel_archive.
+// archived сообщает, что посылка найдена в архиве. Если её нет нигде, возвращает ErrParcelNotFound.
+func (s ParcelStore) GetAnywhere(number int64) (p Parcel, archived bool, err error) {
+	span := s.startSpan("GetAnywhere", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	p, err = s.queryParcel("number = ? AND deleted_at IS NULL", number)
+	if !errors.Is(err, ErrParcelNotFound) {
+		return p, false, err
+	}
+
+	p, err = scanParcel(s.read.QueryRow("SELECT "+parcelColumns+" FROM parcel_archive WHERE number = ?", number))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Parcel{}, false, ErrParcelNotFound
+	}
+	if err != nil {
+		return Parcel{}, false, err
+	}
+
+	return p, true, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetAnywhere проверяет поиск посылки в основной и архивной таблицах
+func TestGetAnywhere(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	live, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	archived, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.ErrorIs(t, store.Archive(archived), ErrParcelNotArchivable)
+	require.NoError(t, store.SetStatus(archived, ParcelStatusSent))
+	require.NoError(t, store.Deliver(archived, ""))
+	want, err := store.Get(archived)
+	require.NoError(t, err)
+	require.NoError(t, store.Archive(archived))
+
+	// check
+	p, inArchive, err := store.GetAnywhere(live)
+	require.NoError(t, err)
+	require.False(t, inArchive)
+	require.Equal(t, live, p.Number)
+
+	_, err = store.Get(archived)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	p, inArchive, err = store.GetAnywhere(archived)
+	require.NoError(t, err)
+	require.True(t, inArchive)
+	require.Equal(t, want, p)
+
+	require.NoError(t, store.Delete(live))
+	_, _, err = store.GetAnywhere(live)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	AttemptOutcomeDelivered = "delivered"
+	AttemptOutcomeFailed    = "failed"
+)
+
+// ErrEmptyOutcome у попытки доставки не указан результат
+var ErrEmptyOutcome = errors.New("delivery attempt outcome is empty")
+
+// DeliveryAttempt попытка доставки посылки курьером.
+// В отличие от истории статусов, попытки записываются и тогда, когда статус не меняется.
+type DeliveryAttempt struct {
+	ID          int64
+	Number      int64
+	Outcome     string // например AttemptOutcomeFailed
+	Note        string
+	AttemptedAt string // RFC3339, UTC
+}
+
+// RecordAttempt записывает попытку доставки посылки с результатом outcome и комментарием курьера
+func (s ParcelStore) RecordAttempt(number int64, outcome, note string) (err error) {
+	span := s.startSpan("RecordAttempt", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	outcome = strings.TrimSpace(outcome)
+	if outcome == "" {
+		return ErrEmptyOutcome
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		if _, err := tx.queryParcel("number = ? AND deleted_at IS NULL", number); err != nil {
+			return err
+		}
+
+		_, err := tx.db.Exec("INSERT INTO parcel_delivery_attempt (number, outcome, note, attempted_at) VALUES (?, ?, ?, ?)",
+			number, outcome, sql.NullString{String: note, Valid: note != ""}, formatTime(tx.cfg.Now()))
+		return err
+	})
+}
+
+// GetAttempts возвращает попытки доставки посылки в порядке времени
+func (s ParcelStore) GetAttempts(number int64) (res []DeliveryAttempt, err error) {
+	span := s.startSpan("GetAttempts", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query("SELECT id, number, outcome, note, attempted_at FROM parcel_delivery_attempt WHERE number = ? ORDER BY attempted_at, id",
+		number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a DeliveryAttempt
+		var note sql.NullString
+		if err := rows.Scan(&a.ID, &a.Number, &a.Outcome, &note, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		a.Note = note.String
+		res = append(res, a)
+	}
+
+	return res, rows.Err()
+}
+
+// ReconcileFromAttempts выводит статус посылки из попыток доставки: если последние
+// StoreConfig.MaxFailedAttempts попыток подряд неудачны, посылка переводится
+// в StoreConfig.FailedAttemptsStatus (по умолчанию returned).
+// Если правило не сработало или не задано (MaxFailedAttempts = 0), статус не меняется.
+// Недопустимый из текущего статуса переход — ошибка ErrInvalidTransition.
+func (s ParcelStore) ReconcileFromAttempts(number int64) (err error) {
+	span := s.startSpan("ReconcileFromAttempts", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	return s.WithTx(func(tx ParcelStore) error {
+		p, err := tx.GetForUpdate(number)
+		if err != nil {
+			return err
+		}
+
+		attempts, err := tx.GetAttempts(number)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for i := len(attempts) - 1; i >= 0 && attempts[i].Outcome == AttemptOutcomeFailed; i-- {
+			failed++
+		}
+
+		target := tx.cfg.FailedAttemptsStatus
+		if tx.cfg.MaxFailedAttempts <= 0 || failed < tx.cfg.MaxFailedAttempts || p.Status == target {
+			return nil
+		}
+		if !CanTransition(p.Status, target) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, p.Status, target)
+		}
+
+		return tx.SetStatus(number, target)
+	})
+}
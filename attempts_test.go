@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeliveryAttempts проверяет запись и чтение попыток доставки
+func TestDeliveryAttempts(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, "никого нет дома"))
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, ""))
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeDelivered, ""))
+
+	// check
+	attempts, err := store.GetAttempts(id)
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	require.Equal(t, AttemptOutcomeFailed, attempts[0].Outcome)
+	require.Equal(t, "никого нет дома", attempts[0].Note)
+	require.Equal(t, AttemptOutcomeDelivered, attempts[2].Outcome)
+	for _, a := range attempts {
+		require.Equal(t, id, a.Number)
+	}
+
+	// статус от попыток не меняется
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusSent, stored.Status)
+
+	require.ErrorIs(t, store.RecordAttempt(id, " ", ""), ErrEmptyOutcome)
+	require.ErrorIs(t, store.RecordAttempt(-1, AttemptOutcomeFailed, ""), ErrParcelNotFound)
+}
+
+// TestReconcileFromAttempts проверяет перевод посылки в returned после неудачных попыток
+func TestReconcileFromAttempts(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStoreWithConfig(db, StoreConfig{MaxFailedAttempts: 2})
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, ""))
+	require.NoError(t, store.ReconcileFromAttempts(id))
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusSent, stored.Status)
+
+	// без настройки правило не действует
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, ""))
+	require.NoError(t, NewParcelStore(db).ReconcileFromAttempts(id))
+	stored, err = store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusSent, stored.Status)
+
+	require.NoError(t, store.ReconcileFromAttempts(id))
+	stored, err = store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusReturned, stored.Status)
+
+	// повторная сверка ничего не меняет
+	require.NoError(t, store.ReconcileFromAttempts(id))
+
+	// зарегистрированную посылку вернуть нельзя
+	id, err = store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, ""))
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, ""))
+	require.ErrorIs(t, store.ReconcileFromAttempts(id), ErrInvalidTransition)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AuditEntry запись журнала аудита parcel_audit об изменении посылки
+type AuditEntry struct {
+	ID        int64
+	Number    int64
+	Operation string // например "set status"
+	Before    string // посылка до изменения в JSON, пустая строка — её не было
+	After     string // посылка после изменения в JSON, пустая строка — её удалили
+	Actor     string // кто изменил, см. ContextWithActor
+	CreatedAt string // RFC3339, UTC
+}
+
+type actorKey struct{}
+
+// ContextWithActor возвращает контекст с именем того, кто выполняет изменения
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext возвращает актора, заданного ContextWithActor, или пустую строку
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithContext возвращает копию хранилища, которая пишет в журнал аудита актора из ctx
+// и открывает спаны дочерними к спану из ctx
+func (s ParcelStore) WithContext(ctx context.Context) ParcelStore {
+	s.actor = ActorFromContext(ctx)
+	s.ctx = ctx
+	return s
+}
+
+// tracksChanges сообщает, нужно ли методу обернуть изменение в audited:
+// включён журнал аудита или outbox и изменение ещё не пишется внешним вызовом
+func (s ParcelStore) tracksChanges() bool {
+	return (s.cfg.Audit || s.cfg.Outbox) && !s.auditing
+}
+
+// audited выполняет изменение посылки number в транзакции вместе с записью в журнал аудита
+// и, если включён StoreConfig.Outbox, в outbox.
+// fn возвращает номер изменённой посылки, для Add он становится известен только после вставки;
+// 0 означает, что посылка не изменилась, и тогда ничего не пишется.
+// Пока идёт fn, методы tx не пишут в журнал сами, чтобы одно изменение не записалось дважды.
+func (s ParcelStore) audited(op string, number int64, fn func(tx ParcelStore) (int64, error)) (n int64, err error) {
+	err = s.WithTx(func(tx ParcelStore) error {
+		tx.auditing = true
+
+		before, err := tx.auditSnapshot(number)
+		if err != nil {
+			return err
+		}
+
+		n, err = fn(tx)
+		if err != nil || n == 0 {
+			return err
+		}
+
+		after, err := tx.auditSnapshot(n)
+		if err != nil {
+			return err
+		}
+
+		return tx.recordChange(op, n, before, after)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// trackMany выполняет массовое изменение fn в транзакции и, если включён журнал аудита или outbox,
+// пишет в них по записи на каждую посылку из "SELECT number FROM parcel "+where.
+// Посылки выбираются до fn, так что where должен совпадать с условием самого изменения.
+func (s ParcelStore) trackMany(op, where string, args []any, fn func(tx ParcelStore) error) error {
+	if !s.tracksChanges() {
+		return s.WithTx(fn)
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		tx.auditing = true
+
+		numbers, err := tx.selectNumbers("SELECT number FROM parcel "+where, args...)
+		if err != nil {
+			return err
+		}
+
+		before := make([]sql.NullString, len(numbers))
+		for i, number := range numbers {
+			if before[i], err = tx.auditSnapshot(number); err != nil {
+				return err
+			}
+		}
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		for i, number := range numbers {
+			after, err := tx.auditSnapshot(number)
+			if err != nil {
+				return err
+			}
+			if err := tx.recordChange(op, number, before[i], after); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// selectNumbers возвращает номера посылок, выбранные запросом query
+func (s ParcelStore) selectNumbers(query string, args ...any) ([]int64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var numbers []int64
+	for rows.Next() {
+		var number int64
+		if err := rows.Scan(&number); err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers, rows.Err()
+}
+
+// recordChange пишет изменение op посылки number в outbox и журнал аудита, если они включены
+func (s ParcelStore) recordChange(op string, number int64, before, after sql.NullString) error {
+	if s.cfg.Outbox {
+		if err := s.writeEvent(op, number, before, after); err != nil {
+			return err
+		}
+	}
+	if !s.cfg.Audit {
+		return nil
+	}
+
+	_, err := s.db.Exec("INSERT INTO parcel_audit (number, operation, before, after, actor, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		number, op, before, after, sql.NullString{String: s.actor, Valid: s.actor != ""}, formatTime(s.cfg.Now()))
+	return err
+}
+
+// auditSnapshot возвращает посылку в JSON для журнала аудита или NULL, если её нет
+func (s ParcelStore) auditSnapshot(number int64) (sql.NullString, error) {
+	if number == 0 {
+		return sql.NullString{}, nil
+	}
+
+	p, err := s.queryParcel("number = ? AND deleted_at IS NULL", number)
+	if errors.Is(err, ErrParcelNotFound) {
+		return sql.NullString{}, nil
+	}
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// queryAuditEntries читает записи журнала аудита, tail — часть запроса после FROM
+func (s ParcelStore) queryAuditEntries(tail string, args ...any) ([]AuditEntry, error) {
+	rows, err := s.read.Query("SELECT id, number, operation, before, after, actor, created_at FROM parcel_audit "+tail, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var before, after, actor sql.NullString
+		if err := rows.Scan(&e.ID, &e.Number, &e.Operation, &before, &after, &actor, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Before = before.String
+		e.After = after.String
+		e.Actor = actor.String
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
+
+// GetAuditTrail возвращает журнал изменений посылки в порядке записи
+func (s ParcelStore) GetAuditTrail(number int64) (res []AuditEntry, err error) {
+	span := s.startSpan("GetAuditTrail", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	return s.queryAuditEntries("WHERE number = ? ORDER BY id", number)
+}
+
+// GetAuditByOperation возвращает записи журнала аудита об операции op (например "repair status")
+// на полуинтервале [from, to) в порядке записи — чтобы найти посылки, затронутые ошибочной операцией
+func (s ParcelStore) GetAuditByOperation(op string, from, to time.Time) (res []AuditEntry, err error) {
+	span := s.startSpan("GetAuditByOperation", attribute.String("audit.operation", op))
+	defer func() { endSpan(span, err) }()
+
+	return s.queryAuditEntries("WHERE operation = ? AND created_at >= ? AND created_at < ? ORDER BY id",
+		op, formatTime(from), formatTime(to))
+}
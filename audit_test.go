@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuditTrail проверяет запись изменений посылки в журнал аудита
+func TestAuditTrail(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := ContextWithActor(context.Background(), "operator-7")
+	store := NewParcelStoreWithConfig(db, StoreConfig{Audit: true}).WithContext(ctx)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	trail, err := store.GetAuditTrail(id)
+	require.NoError(t, err)
+	require.Len(t, trail, 2)
+
+	require.Equal(t, "add", trail[0].Operation)
+	require.Empty(t, trail[0].Before)
+	require.Contains(t, trail[0].After, `"Status":"registered"`)
+	require.Equal(t, "operator-7", trail[0].Actor)
+
+	require.Equal(t, "set status", trail[1].Operation)
+	require.Equal(t, trail[0].After, trail[1].Before)
+	require.Contains(t, trail[1].After, `"Status":"sent"`)
+
+	// ошибка откатывает и изменение, и запись в журнале
+	require.ErrorIs(t, store.SetCarrier(id, ""), ErrUnknownCarrier)
+	trail, err = store.GetAuditTrail(id)
+	require.NoError(t, err)
+	require.Len(t, trail, 2)
+
+	// при слиянии записи появляются у обеих посылок
+	discard, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.Merge(id, discard))
+
+	trail, err = store.GetAuditTrail(discard)
+	require.NoError(t, err)
+	require.Equal(t, "merge", trail[len(trail)-1].Operation)
+	require.Empty(t, trail[len(trail)-1].After)
+
+	trail, err = store.GetAuditTrail(id)
+	require.NoError(t, err)
+	require.Len(t, trail, 3)
+	require.Equal(t, "merge", trail[2].Operation)
+
+	// без Audit журнал не пишется
+	require.NoError(t, NewParcelStore(db).SetAddress(id, "new address"))
+	trail, err = store.GetAuditTrail(id)
+	require.NoError(t, err)
+	require.Len(t, trail, 3)
+}
+
+// TestGetAuditByOperation проверяет выборку журнала аудита по операции и времени
+func TestGetAuditByOperation(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	at := time.Date(2004, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(randRange.Int63n(10_000_000)) * time.Second)
+	store := NewParcelStoreWithConfig(db, StoreConfig{Audit: true, Now: func() time.Time { return at }})
+
+	// add
+	parcel := getTestParcel()
+	parcel.CreatedAt = formatTime(at)
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.RepairStatus(id, ParcelStatusDelivered))
+
+	// check
+	entries, err := store.GetAuditByOperation("repair status", at, at.Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, id, entries[0].Number)
+	require.Contains(t, entries[0].After, `"Status":"delivered"`)
+
+	entries, err = store.GetAuditByOperation("repair status", at.Add(time.Second), at.Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+// TestAuditBulkChanges проверяет запись массовых изменений, смены номера и архивации в журнал аудита
+func TestAuditBulkChanges(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStoreWithConfig(db, StoreConfig{Audit: true})
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000) + 1
+
+	// add
+	first, err := store.Add(parcel)
+	require.NoError(t, err)
+	second, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	n, err := store.TransferParcels(parcel.Client, parcel.Client+1)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	for _, number := range []int64{first, second} {
+		trail, err := store.GetAuditTrail(number)
+		require.NoError(t, err)
+		require.Len(t, trail, 2)
+		require.Equal(t, "transfer", trail[1].Operation)
+		require.Contains(t, trail[1].After, fmt.Sprintf(`"Client":%d`, parcel.Client+1))
+	}
+
+	renumbered := 900_000_000 + randRange.Int63n(100_000_000)
+	require.NoError(t, store.ChangeNumber(second, renumbered))
+	trail, err := store.GetAuditTrail(second)
+	require.NoError(t, err)
+	require.Len(t, trail, 2)
+	trail, err = store.GetAuditTrail(renumbered)
+	require.NoError(t, err)
+	require.Len(t, trail, 1)
+	require.Equal(t, "change number", trail[0].Operation)
+	require.Contains(t, trail[0].Before, fmt.Sprintf(`"Number":%d`, second))
+
+	require.NoError(t, store.RepairStatus(first, ParcelStatusDelivered))
+	require.NoError(t, store.Archive(first))
+	trail, err = store.GetAuditTrail(first)
+	require.NoError(t, err)
+	require.Equal(t, "archive", trail[len(trail)-1].Operation)
+	require.Empty(t, trail[len(trail)-1].After)
+}
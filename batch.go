@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ParcelError ошибка пакетной операции над конкретной посылкой.
+// Достаётся из ошибки AddBatch, SetStatusBatch, ApplyStatuses и Import через errors.As.
+type ParcelError struct {
+	Index  int    // позиция посылки во входных данных
+	Number int64  // номер посылки, 0 если она ещё не добавлена
+	Op     string // операция: "add", "set status", "apply status"
+	Err    error
+}
+
+func (e *ParcelError) Error() string {
+	if e.Number == 0 {
+		return fmt.Sprintf("%s parcel at index %d: %v", e.Op, e.Index, e.Err)
+	}
+	return fmt.Sprintf("%s parcel %d: %v", e.Op, e.Number, e.Err)
+}
+
+func (e *ParcelError) Unwrap() error {
+	return e.Err
+}
+
+// ErrBatchTooLarge пачка больше StoreConfig.MaxBatchSize, а ChunkBatches не задан
+var ErrBatchTooLarge = errors.New("batch is too large")
+
+// forEachBatch вызывает fn для полуинтервалов [start, end) из n элементов не длиннее StoreConfig.MaxBatchSize.
+// Если элементов больше, а StoreConfig.ChunkBatches не задан, fn не вызывается и возвращается ErrBatchTooLarge.
+func (s ParcelStore) forEachBatch(n int, fn func(start, end int) error) error {
+	size := s.cfg.MaxBatchSize
+	if size < 0 || n <= size {
+		return fn(0, n)
+	}
+	if !s.cfg.ChunkBatches {
+		return fmt.Errorf("%w: %d items, at most %d allowed", ErrBatchTooLarge, n, size)
+	}
+
+	for start := 0; start < n; start += size {
+		if err := fn(start, min(start+size, n)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddBatch добавляет посылки в одной транзакции и возвращает их номера в том же порядке.
+// Если хотя бы одна посылка не добавилась, не добавляется ни одна,
+// а ошибка содержит *ParcelError с индексом этой посылки.
+// Посылок может быть не больше StoreConfig.MaxBatchSize; с ChunkBatches большая пачка
+// добавляется по транзакции на каждые MaxBatchSize посылок, и при ошибке уже добавленные
+// транзакции не откатываются — их номера возвращаются вместе с ошибкой.
+func (s ParcelStore) AddBatch(parcels []Parcel) (numbers []int64, err error) {
+	span := s.startSpan("AddBatch", attribute.Int("parcel.count", len(parcels)))
+	defer func() { endSpan(span, err) }()
+
+	err = s.forEachBatch(len(parcels), func(start, end int) error {
+		var added []int64
+		err := s.WithTx(func(tx ParcelStore) error {
+			added = make([]int64, 0, end-start)
+			for i := start; i < end; i++ {
+				number, err := tx.Add(parcels[i])
+				if err != nil {
+					return &ParcelError{Index: i, Op: "add", Err: err}
+				}
+				added = append(added, number)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		numbers = append(numbers, added...)
+		return nil
+	})
+	if err != nil {
+		if !s.cfg.ChunkBatches {
+			return nil, err
+		}
+		return numbers, err
+	}
+
+	return numbers, nil
+}
+
+// ValidateBatch проверяет посылки так же, как их проверила бы Add, но ничего не пишет в БД,
+// например для предпросмотра импорта. Возвращает ошибки по индексам parcels, nil для корректных посылок.
+func (s ParcelStore) ValidateBatch(parcels []Parcel) []error {
+	errs := make([]error, len(parcels))
+	for i, p := range parcels {
+		_, errs[i] = s.prepare(p)
+	}
+
+	return errs
+}
+
+// SetStatusBatch переводит посылки в статус status в одной транзакции.
+// Если хотя бы одну посылку перевести не удалось, не меняется ни одна,
+// а ошибка содержит *ParcelError с номером этой посылки.
+// Ограничение размера пачки такое же, как у AddBatch.
+func (s ParcelStore) SetStatusBatch(numbers []int64, status string) (err error) {
+	span := s.startSpan("SetStatusBatch", attribute.Int("parcel.count", len(numbers)))
+	defer func() { endSpan(span, err) }()
+
+	return s.forEachBatch(len(numbers), func(start, end int) error {
+		return s.WithTx(func(tx ParcelStore) error {
+			for i := start; i < end; i++ {
+				if err := tx.SetStatus(numbers[i], status); err != nil {
+					return &ParcelError{Index: i, Number: numbers[i], Op: "set status", Err: err}
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// ApplyStatusesError посылки, которые ApplyStatuses не смогла перевести в нужный статус
+type ApplyStatusesError struct {
+	Failed []*ParcelError
+}
+
+func (e *ApplyStatusesError) Error() string {
+	return fmt.Sprintf("apply statuses: %d parcels failed, first: %v", len(e.Failed), e.Failed[0])
+}
+
+func (e *ApplyStatusesError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, err := range e.Failed {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Numbers возвращает номера посылок, которые не удалось обновить
+func (e *ApplyStatusesError) Numbers() []int64 {
+	numbers := make([]int64, len(e.Failed))
+	for i, err := range e.Failed {
+		numbers[i] = err.Number
+	}
+	return numbers
+}
+
+// ApplyStatuses переводит каждую посылку из updates в свой статус в одной транзакции,
+// например при сверке с внешней системой. Посылки обрабатываются по возрастанию номера,
+// в этом порядке считается Index в *ParcelError. Посылки, уже находящиеся в нужном статусе, пропускаются.
+// Отсутствующие и задержанные (Hold) посылки и недопустимые переходы не мешают остальным обновлениям:
+// они собираются в *ApplyStatusesError, а удачные обновления сохраняются.
+// Прочие ошибки откатывают транзакцию целиком.
+// Ограничение размера пачки такое же, как у AddBatch, с ChunkBatches каждая пачка — своя транзакция.
+func (s ParcelStore) ApplyStatuses(updates map[int64]string) (err error) {
+	span := s.startSpan("ApplyStatuses", attribute.Int("parcel.count", len(updates)))
+	defer func() { endSpan(span, err) }()
+
+	numbers := make([]int64, 0, len(updates))
+	for number := range updates {
+		numbers = append(numbers, number)
+	}
+	slices.Sort(numbers)
+
+	var failed []*ParcelError
+	err = s.forEachBatch(len(numbers), func(start, end int) error {
+		var batchFailed []*ParcelError
+		err := s.WithTx(func(tx ParcelStore) error {
+			// при повторе транзакции ошибки собираются заново
+			batchFailed = nil
+			for i := start; i < end; i++ {
+				number := numbers[i]
+				status := updates[number]
+
+				p, err := tx.GetForUpdate(number)
+				if errors.Is(err, ErrParcelNotFound) {
+					batchFailed = append(batchFailed, &ParcelError{Index: i, Number: number, Op: "apply status", Err: err})
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				if p.Status == status {
+					continue
+				}
+				if !CanTransition(p.Status, status) {
+					err := fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, p.Status, status)
+					batchFailed = append(batchFailed, &ParcelError{Index: i, Number: number, Op: "apply status", Err: err})
+					continue
+				}
+
+				err = tx.SetStatus(number, status)
+				if errors.Is(err, ErrSignatureRequired) || errors.Is(err, ErrParcelOnHold) {
+					batchFailed = append(batchFailed, &ParcelError{Index: i, Number: number, Op: "apply status", Err: err})
+					continue
+				}
+				if err != nil {
+					return &ParcelError{Index: i, Number: number, Op: "apply status", Err: err}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		failed = append(failed, batchFailed...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return &ApplyStatusesError{Failed: failed}
+	}
+
+	return nil
+}
+
+// Import добавляет посылки пачками по batchSize (по умолчанию loadBatchSize, не больше
+// StoreConfig.MaxBatchSize), каждая пачка — отдельная транзакция AddBatch,
+// поэтому блокировка на запись не держится на весь импорт.
+// После каждой пачки вызывается progress (если задан) с количеством обработанных посылок.
+// Ошибка пачки откатывает только её: по умолчанию импорт на этом останавливается
+// и done в последнем вызове progress показывает, с какой посылки его продолжить;
+// с StoreConfig.ImportContinueOnError импорт идёт дальше и возвращает все ошибки разом.
+// Между пачками выдерживается пауза StoreConfig.ImportBatchDelay.
+func (s ParcelStore) Import(parcels []Parcel, batchSize int, progress func(done, total int)) (err error) {
+	span := s.startSpan("Import", attribute.Int("parcel.count", len(parcels)))
+	defer func() { endSpan(span, err) }()
+
+	if batchSize <= 0 {
+		batchSize = loadBatchSize
+	}
+	if s.cfg.MaxBatchSize > 0 {
+		batchSize = min(batchSize, s.cfg.MaxBatchSize)
+	}
+
+	var errs []error
+	for start := 0; start < len(parcels); start += batchSize {
+		if start > 0 && s.cfg.ImportBatchDelay > 0 {
+			time.Sleep(s.cfg.ImportBatchDelay)
+		}
+
+		end := min(start+batchSize, len(parcels))
+		if _, err := s.AddBatch(parcels[start:end]); err != nil {
+			// Index в ошибке AddBatch считается от начала пачки, а не всего импорта
+			var parcelErr *ParcelError
+			if errors.As(err, &parcelErr) {
+				parcelErr.Index += start
+			}
+			err = fmt.Errorf("import parcels %d-%d: %w", start, end-1, err)
+			if !s.cfg.ImportContinueOnError {
+				return err
+			}
+			errs = append(errs, err)
+		}
+
+		if progress != nil {
+			progress(end, len(parcels))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestImport проверяет импорт пачками, прогресс и обработку ошибок пачки
+func TestImport(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	client := randRange.Int63n(10_000_000)
+	parcels := make([]Parcel, 5)
+	for i := range parcels {
+		parcels[i] = getTestParcel()
+		parcels[i].Client = client
+	}
+	// посылка без адреса не пройдёт проверку, вместе с ней откатится вся вторая пачка
+	parcels[3].Address = ""
+	parcels[3].AddressSet = false
+
+	// add
+	var progress [][2]int
+	store := NewParcelStore(db)
+	err = store.Import(parcels, 2, func(done, total int) {
+		progress = append(progress, [2]int{done, total})
+	})
+
+	// check
+	require.ErrorIs(t, err, ErrIncompleteParcel)
+	require.Equal(t, [][2]int{{2, 5}}, progress)
+
+	var parcelErr *ParcelError
+	require.ErrorAs(t, err, &parcelErr)
+	require.Equal(t, 3, parcelErr.Index)
+
+	stored, err := store.GetByClient(client)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+
+	// с ImportContinueOnError импорт доходит до конца
+	progress = nil
+	store = NewParcelStoreWithConfig(db, StoreConfig{ImportContinueOnError: true})
+	err = store.Import(parcels, 2, func(done, total int) {
+		progress = append(progress, [2]int{done, total})
+	})
+	require.ErrorIs(t, err, ErrIncompleteParcel)
+	require.Equal(t, [][2]int{{2, 5}, {4, 5}, {5, 5}}, progress)
+
+	stored, err = store.GetByClient(client)
+	require.NoError(t, err)
+	require.Len(t, stored, 5)
+}
+
+// TestSetStatusBatchError проверяет, что ошибка пакетной смены статуса указывает на посылку
+func TestSetStatusBatchError(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// для sent без адреса хук читает посылку, поэтому отсутствующая посылка даёт ошибку
+	store := NewParcelStoreWithConfig(db, StoreConfig{
+		DefaultAddress: map[string]func(p Parcel) string{
+			ParcelStatusSent: func(p Parcel) string { return "warehouse" },
+		},
+	})
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(id))
+
+	// check
+	err = store.SetStatusBatch([]int64{id}, ParcelStatusSent)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	var parcelErr *ParcelError
+	require.ErrorAs(t, err, &parcelErr)
+	require.Equal(t, id, parcelErr.Number)
+	require.Equal(t, 0, parcelErr.Index)
+	require.Equal(t, "set status", parcelErr.Op)
+}
+
+// TestApplyStatuses проверяет сверку статусов с отчётом о неудачных посылках
+func TestApplyStatuses(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	sent, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(sent, ParcelStatusSent))
+
+	registered, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	deleted, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(deleted))
+
+	same, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	err = store.ApplyStatuses(map[int64]string{
+		sent:       ParcelStatusDelivered,
+		registered: ParcelStatusDelivered,
+		deleted:    ParcelStatusSent,
+		same:       ParcelStatusRegistered,
+	})
+	require.ErrorIs(t, err, ErrInvalidTransition)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	var applyErr *ApplyStatusesError
+	require.ErrorAs(t, err, &applyErr)
+	require.Equal(t, []int64{registered, deleted}, applyErr.Numbers())
+
+	stored, err := store.Get(sent)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusDelivered, stored.Status)
+
+	stored, err = store.Get(registered)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusRegistered, stored.Status)
+
+	require.NoError(t, store.ApplyStatuses(map[int64]string{registered: ParcelStatusSent}))
+}
+
+// TestValidateBatch проверяет проверку пачки посылок без записи в БД
+func TestValidateBatch(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	incomplete := getTestParcel()
+	incomplete.Address = " "
+	incomplete.AddressSet = false
+
+	future := getTestParcel()
+	future.CreatedAt = formatTime(time.Now().Add(time.Hour))
+
+	// check
+	errs := store.ValidateBatch([]Parcel{getTestParcel(), incomplete, future})
+	require.Len(t, errs, 3)
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[1], ErrIncompleteParcel)
+	require.ErrorIs(t, errs[2], ErrInvalidCreatedAt)
+
+	require.Empty(t, store.ValidateBatch(nil))
+}
+
+// TestMaxBatchSize проверяет ограничение размера пачки и деление на части
+func TestMaxBatchSize(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	client := randRange.Int63n(10_000_000)
+	parcels := make([]Parcel, 3)
+	for i := range parcels {
+		parcels[i] = getTestParcel()
+		parcels[i].Client = client
+	}
+
+	// check
+	strict := NewParcelStoreWithConfig(db, StoreConfig{MaxBatchSize: 2})
+	_, err = strict.AddBatch(parcels)
+	require.ErrorIs(t, err, ErrBatchTooLarge)
+
+	stored, err := strict.GetByClient(client)
+	require.NoError(t, err)
+	require.Empty(t, stored)
+
+	chunked := NewParcelStoreWithConfig(db, StoreConfig{MaxBatchSize: 2, ChunkBatches: true})
+	numbers, err := chunked.AddBatch(parcels)
+	require.NoError(t, err)
+	require.Len(t, numbers, 3)
+
+	owned, notOwned, err := chunked.FilterOwned(client, append(numbers, -1))
+	require.NoError(t, err)
+	require.Equal(t, numbers, owned)
+	require.Equal(t, []int64{-1}, notOwned)
+
+	require.ErrorIs(t, strict.SetStatusBatch(numbers, ParcelStatusSent), ErrBatchTooLarge)
+	require.NoError(t, chunked.SetStatusBatch(numbers, ParcelStatusSent))
+
+	// частично добавленная пачка: номера первой части возвращаются вместе с ошибкой
+	parcels[2].Status = "unknown"
+	numbers, err = chunked.AddBatch(parcels)
+	require.ErrorIs(t, err, ErrUnknownStatus)
+	require.Len(t, numbers, 2)
+}
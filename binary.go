@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// parcelBinaryVersion версия формата MarshalBinary, меняется при изменении набора полей
+const parcelBinaryVersion = 11
+
+// ErrInvalidBinary данные не похожи на результат Parcel.MarshalBinary
+var ErrInvalidBinary = errors.New("invalid parcel binary data")
+
+// MarshalBinary кодирует посылку для передачи между сервисами:
+// байт версии, затем Number, Client, Priority, OnHold и AddressSet (0 или 1) как varint и строки с длиной-префиксом varint.
+// Время хранится строками RFC3339, как в БД, поэтому передаётся без потерь.
+func (p Parcel) MarshalBinary() ([]byte, error) {
+	strs := p.binaryStrings()
+
+	size := 1 + 5*binary.MaxVarintLen64
+	for _, s := range strs {
+		size += binary.MaxVarintLen64 + len(*s)
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, parcelBinaryVersion)
+	b = binary.AppendVarint(b, p.Number)
+	b = binary.AppendVarint(b, p.Client)
+	b = binary.AppendVarint(b, int64(p.Priority))
+	for _, flag := range []bool{p.OnHold, p.AddressSet} {
+		var v int64
+		if flag {
+			v = 1
+		}
+		b = binary.AppendVarint(b, v)
+	}
+	for _, s := range strs {
+		b = binary.AppendUvarint(b, uint64(len(*s)))
+		b = append(b, *s...)
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary разбирает данные, записанные MarshalBinary
+func (p *Parcel) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != parcelBinaryVersion {
+		return fmt.Errorf("%w: unsupported version", ErrInvalidBinary)
+	}
+	data = data[1:]
+
+	var res Parcel
+	var priority, onHold, addressSet int64
+	for _, n := range []*int64{&res.Number, &res.Client, &priority, &onHold, &addressSet} {
+		v, k := binary.Varint(data)
+		if k <= 0 {
+			return fmt.Errorf("%w: bad number", ErrInvalidBinary)
+		}
+		*n = v
+		data = data[k:]
+	}
+	res.Priority = int(priority)
+	res.OnHold = onHold != 0
+	res.AddressSet = addressSet != 0
+
+	for _, s := range res.binaryStrings() {
+		l, k := binary.Uvarint(data)
+		if k <= 0 || l > uint64(len(data)-k) {
+			return fmt.Errorf("%w: bad string", ErrInvalidBinary)
+		}
+		*s = string(data[k : k+int(l)])
+		data = data[k+int(l):]
+	}
+
+	if len(data) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidBinary, len(data))
+	}
+
+	*p = res
+	return nil
+}
+
+// binaryStrings строковые поля посылки в порядке кодирования
+func (p *Parcel) binaryStrings() []*string {
+	return []*string{&p.Status, &p.Address, &p.CreatedAt, &p.ExternalRef, &p.City,
+		&p.Note, &p.ScheduledAt, &p.DeletedAt, &p.PublicID, &p.SignedBy, &p.ClientCode,
+		&p.ClaimedBy, &p.Carrier, &p.Street, &p.PostalCode, &p.Country, &p.HoldReason, &p.RecipientPhone, &p.RecipientName}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = Parcel{}
+	_ encoding.BinaryUnmarshaler = (*Parcel)(nil)
+)
+
+// TestParcelBinary проверяет, что бинарное кодирование сохраняет все поля посылки
+func TestParcelBinary(t *testing.T) {
+	// prepare
+	parcel := Parcel{
+		Number:         -42,
+		Client:         1_000_000_007,
+		Status:         ParcelStatusSent,
+		Address:        "Москва, ул. Льва Толстого, 16",
+		AddressSet:     true,
+		CreatedAt:      "2024-01-02T03:04:05Z",
+		ExternalRef:    "order_1",
+		City:           "Москва",
+		Note:           "позвонить за час",
+		ScheduledAt:    "2024-01-03T10:00:00+03:00",
+		DeletedAt:      "2024-02-01T00:00:00Z",
+		PublicID:       RandomPublicID(),
+		SignedBy:       "Иванов И. И.",
+		ClientCode:     "ACME-42",
+		ClaimedBy:      "worker-1",
+		Carrier:        "СДЭК",
+		Street:         "ул. Льва Толстого, 16",
+		PostalCode:     "119021",
+		Country:        "Россия",
+		Priority:       2,
+		OnHold:         true,
+		HoldReason:     "проверка таможни",
+		RecipientPhone: "+79123456789",
+		RecipientName:  "Петров П. П.",
+	}
+	// новое поле Parcel нужно добавить в MarshalBinary и сюда
+	v := reflect.ValueOf(parcel)
+	for i := 0; i < v.NumField(); i++ {
+		require.False(t, v.Field(i).IsZero(), "field %s is not set", v.Type().Field(i).Name)
+	}
+
+	// check
+	data, err := parcel.MarshalBinary()
+	require.NoError(t, err)
+
+	var decoded Parcel
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.Equal(t, parcel, decoded)
+
+	require.ErrorIs(t, decoded.UnmarshalBinary(nil), ErrInvalidBinary)
+	require.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), ErrInvalidBinary)
+	require.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), ErrInvalidBinary)
+	require.Equal(t, parcel, decoded)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen BreakerStore не передаёт вызов хранилищу: после череды сбоев идёт пауза
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// domainErrors ошибки предметной области: хранилище работает, просто операция не разрешена
+// или посылки нет. BreakerStore не считает их сбоями.
+var domainErrors = []error{
+	ErrParcelNotFound, ErrParcelNotDeletable, ErrParcelDeleted, ErrParcelOnHold,
+	ErrInvalidTransition, ErrUnknownStatus, ErrIncompleteParcel, ErrInvalidCreatedAt, ErrInvalidScheduledAt,
+	ErrAddressTooLong, ErrNoteTooLong, ErrSignatureRequired,
+}
+
+// BreakerStore защищает больную БД от лавины запросов (circuit breaker) поверх другого Store.
+// После threshold сбоев подряд цепь размыкается: на время cooldown все вызовы сразу
+// возвращают ErrCircuitOpen. Затем пропускается один пробный вызов: если он удался,
+// цепь замыкается, если нет — снова размыкается на cooldown.
+// Ошибки из domainErrors сбоями не считаются. Безопасен для конкурентного использования.
+type BreakerStore struct {
+	store     Store
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int       // сбоев подряд
+	openedAt time.Time // когда цепь разомкнулась, нулевое — замкнута
+	probing  bool      // идёт пробный вызов, остальные ждут его результата
+}
+
+var _ Store = (*BreakerStore)(nil)
+
+// NewBreakerStore создаёт BreakerStore, который размыкается после threshold сбоев подряд на cooldown
+func NewBreakerStore(store Store, threshold int, cooldown time.Duration) *BreakerStore {
+	return &BreakerStore{
+		store:     store,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// BreakerMiddleware оборачивает Store в BreakerStore с заданными порогом и паузой
+func BreakerMiddleware(threshold int, cooldown time.Duration) Middleware {
+	return func(next Store) Store {
+		return NewBreakerStore(next, threshold, cooldown)
+	}
+}
+
+func (b *BreakerStore) Add(p Parcel) (int64, error) {
+	return breakerCall(b, func() (int64, error) { return b.store.Add(p) })
+}
+
+func (b *BreakerStore) Get(number int64) (Parcel, error) {
+	return breakerCall(b, func() (Parcel, error) { return b.store.Get(number) })
+}
+
+func (b *BreakerStore) GetByClient(client int64) ([]Parcel, error) {
+	return breakerCall(b, func() ([]Parcel, error) { return b.store.GetByClient(client) })
+}
+
+func (b *BreakerStore) SetStatus(number int64, status string) error {
+	_, err := breakerCall(b, func() (struct{}, error) { return struct{}{}, b.store.SetStatus(number, status) })
+	return err
+}
+
+func (b *BreakerStore) SetAddress(number int64, address string) error {
+	_, err := breakerCall(b, func() (struct{}, error) { return struct{}{}, b.store.SetAddress(number, address) })
+	return err
+}
+
+func (b *BreakerStore) Delete(number int64) error {
+	_, err := breakerCall(b, func() (struct{}, error) { return struct{}{}, b.store.Delete(number) })
+	return err
+}
+
+// breakerCall выполняет fn, если цепь это позволяет, и учитывает результат
+func breakerCall[T any](b *BreakerStore, fn func() (T, error)) (T, error) {
+	if err := b.allow(); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	res, err := fn()
+	b.record(err)
+
+	return res, err
+}
+
+// allow возвращает ErrCircuitOpen, если вызов сейчас пропускать нельзя
+func (b *BreakerStore) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.probing || b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return ErrCircuitOpen
+	}
+
+	b.probing = true
+	return nil
+}
+
+// record учитывает результат вызова: сбой приближает размыкание, успех замыкает цепь
+func (b *BreakerStore) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isStoreFailure(err) {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+// isStoreFailure сообщает, что ошибка говорит о сбое хранилища, а не о запрете операции
+func isStoreFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, domain := range domainErrors {
+		if errors.Is(err, domain) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flakyStore отвечает на Get ошибкой err, пока она задана, и считает обращения
+type flakyStore struct {
+	Store
+	err   error
+	calls int
+}
+
+func (s *flakyStore) Get(number int64) (Parcel, error) {
+	s.calls++
+	if s.err != nil {
+		return Parcel{}, s.err
+	}
+	return Parcel{Number: number}, nil
+}
+
+// TestBreakerStore проверяет размыкание цепи после сбоев подряд и её восстановление
+func TestBreakerStore(t *testing.T) {
+	// prepare
+	errDown := errors.New("database is down")
+	flaky := &flakyStore{err: errDown}
+	store := NewBreakerStore(flaky, 2, time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	// check
+	// ошибки предметной области сбоями не считаются
+	flaky.err = ErrParcelNotFound
+	for i := 0; i < 3; i++ {
+		_, err := store.Get(1)
+		require.ErrorIs(t, err, ErrParcelNotFound)
+	}
+
+	flaky.err = errDown
+	for i := 0; i < 2; i++ {
+		_, err := store.Get(1)
+		require.ErrorIs(t, err, errDown)
+	}
+	_, err := store.Get(1)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, 5, flaky.calls)
+
+	// после паузы пробный вызов снова неудачен — цепь опять разомкнута
+	now = now.Add(time.Minute)
+	_, err = store.Get(1)
+	require.ErrorIs(t, err, errDown)
+	_, err = store.Get(1)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	// удачный пробный вызов замыкает цепь
+	now = now.Add(time.Minute)
+	flaky.err = nil
+	p, err := store.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), p.Number)
+	_, err = store.Get(2)
+	require.NoError(t, err)
+	require.Equal(t, 8, flaky.calls)
+}
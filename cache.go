@@ -0,0 +1,130 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CachingStore кэширует результаты Get поверх другого Store.
+// Кэш ограничен по размеру (вытесняются давно не читанные посылки, LRU) и по времени жизни записи.
+// SetStatus, SetAddress и Delete сбрасывают запись об изменённой посылке,
+// поэтому после записи через CachingStore чтение той же посылки не вернёт старые данные.
+// Изменения в обход CachingStore видны только после истечения ttl.
+// Безопасен для конкурентного использования.
+type CachingStore struct {
+	store Store
+	size  int
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[int64]*list.Element
+	lru     *list.List // в начале недавно прочитанные
+	// gen растёт при каждом сбросе: Get не кладёт в кэш данные, прочитанные до записи
+	gen uint64
+}
+
+type cacheEntry struct {
+	number  int64
+	parcel  Parcel
+	expires time.Time
+}
+
+var _ Store = (*CachingStore)(nil)
+
+// NewCachingStore создаёт кэш не больше чем на size посылок, каждая хранится не дольше ttl
+func NewCachingStore(store Store, size int, ttl time.Duration) *CachingStore {
+	return &CachingStore{
+		store:   store,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[int64]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *CachingStore) Get(number int64) (Parcel, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[number]; ok {
+		entry := el.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return entry.parcel, nil
+		}
+		c.remove(number)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	p, err := c.store.Get(number)
+	if err != nil {
+		return Parcel{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen && c.size > 0 {
+		c.put(number, p)
+	}
+
+	return p, nil
+}
+
+func (c *CachingStore) Add(p Parcel) (int64, error) {
+	return c.store.Add(p)
+}
+
+func (c *CachingStore) GetByClient(client int64) ([]Parcel, error) {
+	return c.store.GetByClient(client)
+}
+
+func (c *CachingStore) SetStatus(number int64, status string) error {
+	defer c.invalidate(number)
+	return c.store.SetStatus(number, status)
+}
+
+func (c *CachingStore) SetAddress(number int64, address string) error {
+	defer c.invalidate(number)
+	return c.store.SetAddress(number, address)
+}
+
+func (c *CachingStore) Delete(number int64) error {
+	defer c.invalidate(number)
+	return c.store.Delete(number)
+}
+
+// invalidate сбрасывает запись о посылке после её изменения
+func (c *CachingStore) invalidate(number int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.remove(number)
+}
+
+// put добавляет посылку в кэш, вытесняя самую давно прочитанную при переполнении.
+// Вызывается под c.mu.
+func (c *CachingStore) put(number int64, p Parcel) {
+	entry := &cacheEntry{number: number, parcel: p, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[number]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[number] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back().Value.(*cacheEntry).number)
+	}
+}
+
+// remove удаляет запись из кэша. Вызывается под c.mu.
+func (c *CachingStore) remove(number int64) {
+	if el, ok := c.entries[number]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, number)
+	}
+}
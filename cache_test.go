@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore считает обращения к Get нижележащего хранилища
+type countingStore struct {
+	Store
+	gets int
+}
+
+func (s *countingStore) Get(number int64) (Parcel, error) {
+	s.gets++
+	return s.Store.Get(number)
+}
+
+// TestCachingStore проверяет кэширование Get и сброс кэша при изменениях
+func TestCachingStore(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	counting := &countingStore{Store: NewParcelStore(db)}
+	store := NewCachingStore(counting, 1, time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	first, err := store.Get(id)
+	require.NoError(t, err)
+	second, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	require.Equal(t, 1, counting.gets)
+
+	// запись сбрасывает кэш
+	require.NoError(t, store.SetAddress(id, "new address"))
+	p, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, "new address", p.Address)
+	require.Equal(t, 2, counting.gets)
+
+	// истёкшая запись читается заново
+	now = now.Add(time.Minute)
+	_, err = store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, 3, counting.gets)
+
+	// при переполнении вытесняется старая запись
+	other, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	_, err = store.Get(other)
+	require.NoError(t, err)
+	_, err = store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, 5, counting.gets)
+
+	require.NoError(t, store.Delete(id))
+	_, err = store.Get(id)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+}
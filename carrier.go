@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrUnknownCarrier курьерской службы нет в StoreConfig.Carriers
+var ErrUnknownCarrier = errors.New("unknown carrier")
+
+// SetCarrier назначает посылке курьерскую службу последней мили.
+// Если задан StoreConfig.Carriers, служба должна быть в этом списке.
+func (s ParcelStore) SetCarrier(number int64, carrier string) (err error) {
+	span := s.startSpan("SetCarrier", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("set carrier", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.SetCarrier(number, carrier)
+		})
+		return err
+	}
+
+	carrier = strings.TrimSpace(carrier)
+	if carrier == "" || (s.cfg.Carriers != nil && !slices.Contains(s.cfg.Carriers, carrier)) {
+		return fmt.Errorf("%w: %q", ErrUnknownCarrier, carrier)
+	}
+
+	res, err := s.db.Exec("UPDATE parcel SET carrier = ? WHERE number = ? AND deleted_at IS NULL", carrier, number)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrParcelNotFound
+	}
+
+	return nil
+}
+
+// GetByCarrier возвращает посылки, назначенные курьерской службе, — её маршрутный лист
+func (s ParcelStore) GetByCarrier(carrier string) (res []Parcel, err error) {
+	span := s.startSpan("GetByCarrier")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels("WHERE carrier = ? AND deleted_at IS NULL AND status <> ? ORDER BY number", carrier, ParcelStatusDraft)
+}
+
+// GetUnassigned возвращает зарегистрированные посылки, которым ещё не назначена курьерская служба, —
+// список для экрана раздачи курьерам. Сначала идут посылки с наибольшим Priority,
+// при равном — созданные раньше.
+func (s ParcelStore) GetUnassigned() (res []Parcel, err error) {
+	span := s.startSpan("GetUnassigned")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels("WHERE status = ? AND (carrier IS NULL OR carrier = '') AND deleted_at IS NULL ORDER BY priority DESC, created_at, number",
+		ParcelStatusRegistered)
+}
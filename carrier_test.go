@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetCarrier проверяет назначение курьерской службы и маршрутный лист
+func TestSetCarrier(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	carrier := fmt.Sprintf("courier_%d", randRange.Intn(10_000_000))
+	store := NewParcelStoreWithConfig(db, StoreConfig{Carriers: []string{carrier}})
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	other, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	require.NoError(t, store.SetCarrier(id, carrier))
+	require.ErrorIs(t, store.SetCarrier(other, "unknown"), ErrUnknownCarrier)
+	require.ErrorIs(t, store.SetCarrier(other, ""), ErrUnknownCarrier)
+	require.ErrorIs(t, store.SetCarrier(-1, carrier), ErrParcelNotFound)
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, carrier, stored.Carrier)
+
+	manifest, err := store.GetByCarrier(carrier)
+	require.NoError(t, err)
+	require.Len(t, manifest, 1)
+	require.Equal(t, id, manifest[0].Number)
+}
+
+// TestGetUnassigned проверяет список зарегистрированных посылок без курьерской службы
+func TestGetUnassigned(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	carrier := fmt.Sprintf("courier_%d", randRange.Intn(10_000_000))
+	store := NewParcelStoreWithConfig(db, StoreConfig{Carriers: []string{carrier}})
+
+	// add
+	add := func(priority int, createdAt string) int64 {
+		parcel := getTestParcel()
+		parcel.Priority = priority
+		parcel.CreatedAt = createdAt
+		id, err := store.Add(parcel)
+		require.NoError(t, err)
+		return id
+	}
+	older := add(0, "2024-01-01T00:00:00Z")
+	newer := add(0, "2024-01-02T00:00:00Z")
+	urgent := add(5, "2024-01-03T00:00:00Z")
+	assigned := add(5, "2024-01-01T00:00:00Z")
+	require.NoError(t, store.SetCarrier(assigned, carrier))
+	sent := add(5, "2024-01-01T00:00:00Z")
+	require.NoError(t, store.SetStatus(sent, ParcelStatusSent))
+
+	// check
+	parcels, err := store.GetUnassigned()
+	require.NoError(t, err)
+
+	position := make(map[int64]int)
+	for i, p := range parcels {
+		require.Equal(t, ParcelStatusRegistered, p.Status)
+		require.Empty(t, p.Carrier)
+		position[p.Number] = i
+	}
+	require.NotContains(t, position, assigned)
+	require.NotContains(t, position, sent)
+	require.Contains(t, position, urgent)
+	require.Less(t, position[urgent], position[older])
+	require.Less(t, position[older], position[newer])
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrEmptyWorkerID ClaimNextRegistered вызван без идентификатора обработчика
+var ErrEmptyWorkerID = errors.New("worker id is empty")
+
+// ClaimNextRegistered берёт в обработку самую раннюю зарегистрированную и не задержанную (Hold) посылку:
+// переводит её в статус processing, записывает обработчика в ClaimedBy и возвращает.
+// Одну посылку не могут взять два обработчика: в PostgreSQL строка выбирается
+// с FOR UPDATE SKIP LOCKED, а обновление в любом диалекте проходит, только если
+// статус всё ещё registered. Если свободных посылок нет, возвращает ErrParcelNotFound.
+func (s ParcelStore) ClaimNextRegistered(workerID string) (p Parcel, err error) {
+	span := s.startSpan("ClaimNextRegistered")
+	defer func() { endSpan(span, err) }()
+
+	workerID = strings.TrimSpace(workerID)
+	if workerID == "" {
+		return Parcel{}, ErrEmptyWorkerID
+	}
+
+	where := "status = ? AND on_hold = 0 AND deleted_at IS NULL ORDER BY created_at, number LIMIT 1"
+	if s.cfg.Dialect == DialectPostgres {
+		where += " FOR UPDATE SKIP LOCKED"
+	}
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		for {
+			p, err = tx.queryParcel(where, ParcelStatusRegistered)
+			if err != nil {
+				return err
+			}
+
+			claimed, err := tx.claim(p.Number, workerID)
+			if err != nil {
+				return err
+			}
+			// посылку успел забрать другой обработчик, берём следующую
+			if !claimed {
+				continue
+			}
+
+			p.Status = ParcelStatusProcessing
+			p.ClaimedBy = workerID
+			return nil
+		}
+	})
+	if err != nil {
+		return Parcel{}, err
+	}
+
+	return p, nil
+}
+
+// claim переводит зарегистрированную посылку number в processing за обработчиком workerID.
+// false означает, что посылку уже забрал другой обработчик.
+func (s ParcelStore) claim(number int64, workerID string) (bool, error) {
+	if s.tracksChanges() {
+		n, err := s.audited("claim", number, func(tx ParcelStore) (int64, error) {
+			claimed, err := tx.claim(number, workerID)
+			if !claimed {
+				return 0, err
+			}
+			return number, err
+		})
+		return n != 0, err
+	}
+
+	res, err := s.db.Exec("UPDATE parcel SET status = ?, claimed_by = ? WHERE number = ? AND status = ?",
+		ParcelStatusProcessing, workerID, number, ParcelStatusRegistered)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestClaimNextRegistered проверяет, что обработчики забирают посылки по одной
+func TestClaimNextRegistered(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	// самые ранние посылки в базе, их заберут первыми
+	parcel := getTestParcel()
+	parcel.CreatedAt = "1970-01-01T00:00:00Z"
+	first, err := store.Add(parcel)
+	require.NoError(t, err)
+	parcel.CreatedAt = "1970-01-01T00:00:01Z"
+	second, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	claimed, err := store.ClaimNextRegistered("worker-1")
+	require.NoError(t, err)
+	require.Equal(t, first, claimed.Number)
+	require.Equal(t, ParcelStatusProcessing, claimed.Status)
+	require.Equal(t, "worker-1", claimed.ClaimedBy)
+
+	claimed, err = store.ClaimNextRegistered("worker-2")
+	require.NoError(t, err)
+	require.Equal(t, second, claimed.Number)
+
+	stored, err := store.Get(first)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusProcessing, stored.Status)
+	require.Equal(t, "worker-1", stored.ClaimedBy)
+
+	_, err = store.ClaimNextRegistered(" ")
+	require.ErrorIs(t, err, ErrEmptyWorkerID)
+}
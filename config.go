@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// DefaultMaxBatchSize размер пачки по умолчанию: с запасом меньше ограничения SQLite
+// в 999 параметров на запрос, которое касается FilterOwned
+const DefaultMaxBatchSize = 500
+
+// StoreConfig настройки ParcelStore.
+// Нулевое значение соответствует SQLite с настройками по умолчанию.
+type StoreConfig struct {
+	// Dialect диалект SQL, по умолчанию DialectSQLite
+	Dialect Dialect
+
+	// MaxAddressLen максимальная длина адреса в символах, по умолчанию DefaultMaxTextLen
+	MaxAddressLen int
+	// MaxNoteLen максимальная длина комментария в символах, по умолчанию DefaultMaxTextLen
+	MaxNoteLen int
+	// LengthPolicy что делать со слишком длинным адресом или комментарием,
+	// по умолчанию LengthPolicyReject
+	LengthPolicy LengthPolicy
+	// MaxClockSkew насколько CreatedAt посылки может опережать время сервера, по умолчанию 5 минут
+	MaxClockSkew time.Duration
+	// Now источник текущего времени, по умолчанию time.Now
+	Now func() time.Time
+	// EpochTimestamps сравнивать время создания в запросах по диапазону дат как целые числа
+	// по колонке created_unix (Unix-время), а не как строки created_at
+	EpochTimestamps bool
+
+	// Normalize приводит поля посылки к хранимому виду перед Add, по умолчанию TrimParcel.
+	// SetAddress и GetByAddress нормализуют адрес, передавая посылку, в которой заполнен только Address.
+	Normalize func(p Parcel) Parcel
+
+	// AddressPlaceholder адрес-заглушка, с которым посылку регистрируют до уточнения адреса
+	AddressPlaceholder string
+	// DefaultAddress адрес по умолчанию для посылки, переходящей в статус-ключ без адреса
+	// (пустого или равного AddressPlaceholder), например склад отправителя для возвратов.
+	// Функция получает посылку до смены статуса. По умолчанию не задан.
+	DefaultAddress map[string]func(p Parcel) string
+
+	// IDGenerator выдаёт PublicID для новых посылок, у которых он не задан, например RandomPublicID.
+	// Number остаётся внутренним ключом, наружу отдаётся PublicID. По умолчанию не задан.
+	IDGenerator func() string
+
+	// Carriers список известных курьерских служб для SetCarrier, по умолчанию подходит любая
+	Carriers []string
+
+	// RequireSignature доставлять посылки только через Deliver с подписью получателя,
+	// SetStatus в статус delivered тогда возвращает ErrSignatureRequired
+	RequireSignature bool
+
+	// Audit писать изменения посылок в журнал parcel_audit в той же транзакции, что и само изменение.
+	// В журнал пишут все методы, меняющие посылки, массовые — по записи на каждую затронутую посылку.
+	// Журнал только пополняется: ChangeNumber не меняет прежние записи, а добавляет свою.
+	// PurgeOlderThan тоже добавляет свою, а в прежних записях удалённых посылок стирает только снимки.
+	Audit bool
+
+	// Outbox писать событие о каждом изменении посылки в таблицу parcel_outbox в той же транзакции,
+	// что и само изменение (transactional outbox). События пишут те же методы, что и журнал аудита,
+	// массовые изменения — по событию на каждую посылку;
+	// отдельный процесс забирает их FetchUnpublished и отмечает MarkPublished.
+	Outbox bool
+
+	// MaxFailedAttempts после скольких неудачных попыток доставки подряд ReconcileFromAttempts
+	// переводит посылку в FailedAttemptsStatus, по умолчанию 0 — не переводит
+	MaxFailedAttempts int
+	// FailedAttemptsStatus статус посылки после MaxFailedAttempts неудачных попыток, по умолчанию returned
+	FailedAttemptsStatus string
+
+	// PurgeTerminalOnly удалять в PurgeOlderThan только посылки, закончившие путь
+	// (delivered, lost, returned), по умолчанию удаляются все старые посылки
+	PurgeTerminalOnly bool
+
+	// MaxBatchSize наибольшее количество посылок в одной пачке AddBatch, SetStatusBatch, ApplyStatuses
+	// и FilterOwned, по умолчанию DefaultMaxBatchSize, отрицательное значение снимает ограничение.
+	// Большая пачка — ошибка ErrBatchTooLarge, если не задан ChunkBatches.
+	MaxBatchSize int
+	// ChunkBatches делить пачки больше MaxBatchSize на части вместо ошибки ErrBatchTooLarge.
+	// Каждая часть — отдельная транзакция, так что пачка целиком уже не атомарна.
+	ChunkBatches bool
+
+	// ImportContinueOnError продолжать Import после ошибки в пачке, по умолчанию импорт останавливается
+	ImportContinueOnError bool
+	// ImportBatchDelay пауза между пачками Import, чтобы не занимать БД целиком, по умолчанию без паузы
+	ImportBatchDelay time.Duration
+
+	// SQLLogger логгер для отладки запросов: каждый запрос пишется на уровне Debug
+	// вместе с аргументами, строковые аргументы (кроме статусов) маскируются.
+	// По умолчанию не задан, и запросы не логируются.
+	SQLLogger *slog.Logger
+
+	// ReadReplica реплика для запросов на чтение вне транзакций (Get, GetByClient, Query и другие),
+	// запись и всё внутри WithTx идут в основную базу. По умолчанию не задана, и читается основная база.
+	// Реплика может отставать: посылка, только что добавленная или изменённая, может ещё
+	// не читаться с неё. Если нужно прочитать свою запись, читайте внутри WithTx.
+	ReadReplica *sql.DB
+
+	// IsRetryable отличает временные ошибки, после которых транзакцию WithTx стоит повторить.
+	// По умолчанию IsRetryableSQLite или IsRetryablePostgres в зависимости от Dialect.
+	IsRetryable func(err error) bool
+	// MaxRetries сколько раз повторять транзакцию, по умолчанию 3, отрицательное значение отключает повторы
+	MaxRetries int
+	// RetryDelay пауза перед первым повтором, по умолчанию 10 мс, дальше удваивается
+	RetryDelay time.Duration
+}
+
+// withDefaults подставляет значения по умолчанию для незаданных настроек
+func (c StoreConfig) withDefaults() StoreConfig {
+	if c.Dialect == "" {
+		c.Dialect = DialectSQLite
+	}
+	if c.MaxAddressLen <= 0 {
+		c.MaxAddressLen = DefaultMaxTextLen
+	}
+	if c.MaxNoteLen <= 0 {
+		c.MaxNoteLen = DefaultMaxTextLen
+	}
+	if c.MaxClockSkew <= 0 {
+		c.MaxClockSkew = 5 * time.Minute
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	if c.MaxBatchSize == 0 {
+		c.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if c.Normalize == nil {
+		c.Normalize = TrimParcel
+	}
+	if c.FailedAttemptsStatus == "" {
+		c.FailedAttemptsStatus = ParcelStatusReturned
+	}
+	if c.IsRetryable == nil {
+		c.IsRetryable = IsRetryableSQLite
+		if c.Dialect == DialectPostgres {
+			c.IsRetryable = IsRetryablePostgres
+		}
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 10 * time.Millisecond
+	}
+
+	return c
+}
+
+// Config возвращает действующие настройки хранилища с подставленными значениями по умолчанию,
+// например для отладки или страницы администратора. Это копия: её изменение хранилище не меняет.
+func (s ParcelStore) Config() StoreConfig {
+	cfg := s.cfg
+	cfg.DefaultAddress = maps.Clone(cfg.DefaultAddress)
+	cfg.Carriers = slices.Clone(cfg.Carriers)
+	return cfg
+}
+
+// NewParcelStoreWithConfig создаёт хранилище с заданными настройками
+func NewParcelStoreWithConfig(db *sql.DB, cfg StoreConfig) ParcelStore {
+	cfg = cfg.withDefaults()
+
+	life := &lifecycle{}
+
+	primary := querier{q: db, dialect: cfg.Dialect, log: cfg.SQLLogger, life: life}
+	read := primary
+	if cfg.ReadReplica != nil {
+		read.q = cfg.ReadReplica
+	}
+
+	return ParcelStore{
+		db:   primary,
+		read: read,
+		conn: db,
+		cfg:  cfg,
+		life: life,
+	}
+}
+
+// NewParcelStoreFromDSN открывает базу драйвером driver по dsn, проверяет соединение,
+// создаёт схему через InitSchema и возвращает хранилище, которое владеет этой базой:
+// Close хранилища её закрывает. Хранилище из NewParcelStore и NewParcelStoreWithConfig
+// базой не владеет, её закрывает тот, кто её открыл.
+func NewParcelStoreFromDSN(driver, dsn string, opts ...Option) (*ParcelStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewParcelStore(db, opts...)
+	store.owned = true
+
+	if err := db.Ping(); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+	if err := store.InitSchema(); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	return &store, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// clientCountersSchema таблица счётчиков посылок по клиентам и триггеры, которые её обновляют.
+// Триггеры срабатывают внутри той же транзакции, что и вставка, удаление (в том числе мягкое)
+// или смена клиента посылки, поэтому счётчик меняется атомарно с данными.
+// Триггеры пересоздаются при каждом EnableClientCounters, чтобы подхватить изменения.
+const clientCountersSchema = `
+CREATE TABLE IF NOT EXISTS parcel_client_counter
+(
+    client  integer
+        constraint parcel_client_counter_pk
+            primary key,
+    parcels integer not null
+);
+
+DROP TRIGGER IF EXISTS parcel_client_counter_insert;
+CREATE TRIGGER parcel_client_counter_insert
+    AFTER INSERT
+    ON parcel
+    WHEN NEW.deleted_at IS NULL
+BEGIN
+    INSERT INTO parcel_client_counter (client, parcels) VALUES (NEW.client, 1)
+    ON CONFLICT (client) DO UPDATE SET parcels = parcels + 1;
+END;
+
+DROP TRIGGER IF EXISTS parcel_client_counter_delete;
+CREATE TRIGGER parcel_client_counter_delete
+    AFTER DELETE
+    ON parcel
+    WHEN OLD.deleted_at IS NULL
+BEGIN
+    UPDATE parcel_client_counter SET parcels = parcels - 1 WHERE client = OLD.client;
+END;
+
+DROP TRIGGER IF EXISTS parcel_client_counter_update;
+CREATE TRIGGER parcel_client_counter_update
+    AFTER UPDATE OF client, deleted_at
+    ON parcel
+    WHEN OLD.client IS NOT NEW.client OR (OLD.deleted_at IS NULL) <> (NEW.deleted_at IS NULL)
+BEGIN
+    UPDATE parcel_client_counter SET parcels = parcels - 1 WHERE client = OLD.client AND OLD.deleted_at IS NULL;
+    INSERT INTO parcel_client_counter (client, parcels) SELECT NEW.client, 1 WHERE NEW.deleted_at IS NULL
+    ON CONFLICT (client) DO UPDATE SET parcels = parcels + 1;
+END;
+`
+
+// EnableClientCounters включает счётчики посылок по клиентам: создаёт таблицу
+// parcel_client_counter с триггерами и заполняет её. Повторный вызов безопасен.
+// Счётчики ведутся триггерами SQLite, для других диалектов возвращается ErrUnsupportedDialect.
+func (s ParcelStore) EnableClientCounters() (err error) {
+	span := s.startSpan("EnableClientCounters")
+	defer func() { endSpan(span, err) }()
+
+	if s.cfg.Dialect != DialectSQLite {
+		return fmt.Errorf("client counters: %w", ErrUnsupportedDialect)
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		if _, err := tx.db.Exec(clientCountersSchema); err != nil {
+			return err
+		}
+		return tx.RebuildCounters()
+	})
+}
+
+// RebuildCounters пересчитывает счётчики посылок по клиентам заново, исправляя расхождения
+func (s ParcelStore) RebuildCounters() (err error) {
+	span := s.startSpan("RebuildCounters")
+	defer func() { endSpan(span, err) }()
+
+	return s.WithTx(func(tx ParcelStore) error {
+		if _, err := tx.db.Exec("DELETE FROM parcel_client_counter"); err != nil {
+			return err
+		}
+		_, err := tx.db.Exec("INSERT INTO parcel_client_counter (client, parcels) SELECT client, COUNT(*) FROM parcel WHERE deleted_at IS NULL GROUP BY client")
+		return err
+	})
+}
+
+// GetCachedClientCount возвращает количество неудалённых посылок клиента (включая черновики) из счётчика,
+// не пересчитывая строки parcel. Требует EnableClientCounters.
+func (s ParcelStore) GetCachedClientCount(client int64) (n int, err error) {
+	span := s.startSpan("GetCachedClientCount", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	err = s.read.QueryRow("SELECT parcels FROM parcel_client_counter WHERE client = ?", client).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	return n, err
+}
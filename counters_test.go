@@ -0,0 +1,55 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestClientCounters проверяет ведение счётчиков посылок по клиентам
+func TestClientCounters(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	require.NoError(t, store.EnableClientCounters())
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+
+	// add
+	var numbers []int64
+	for i := 0; i < 3; i++ {
+		id, err := store.Add(parcel)
+		require.NoError(t, err)
+		numbers = append(numbers, id)
+	}
+
+	n, err := store.GetCachedClientCount(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	// delete
+	require.NoError(t, store.Delete(numbers[0]))
+	n, err = store.GetCachedClientCount(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	// мягкое удаление
+	_, err = db.Exec("UPDATE parcel SET deleted_at = ? WHERE number = ?", formatTime(time.Now()), numbers[1])
+	require.NoError(t, err)
+	n, err = store.GetCachedClientCount(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	// rebuild
+	_, err = db.Exec("UPDATE parcel_client_counter SET parcels = 100 WHERE client = ?", parcel.Client)
+	require.NoError(t, err)
+	require.NoError(t, store.RebuildCounters())
+	n, err = store.GetCachedClientCount(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrSignatureRequired доставка без подписи получателя при StoreConfig.RequireSignature
+var ErrSignatureRequired = errors.New("delivery requires recipient signature")
+
+// Deliver переводит отправленную посылку в статус delivered и записывает, кто расписался в получении.
+// Пустой signedBy допускается, только если не задан StoreConfig.RequireSignature.
+func (s ParcelStore) Deliver(number int64, signedBy string) (err error) {
+	span := s.startSpan("Deliver", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("deliver", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.Deliver(number, signedBy)
+		})
+		return err
+	}
+
+	signedBy = strings.TrimSpace(signedBy)
+	if signedBy == "" && s.cfg.RequireSignature {
+		return ErrSignatureRequired
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		p, err := tx.GetForUpdate(number)
+		if err != nil {
+			return err
+		}
+		if p.OnHold {
+			return ErrParcelOnHold
+		}
+		if !CanTransition(p.Status, ParcelStatusDelivered) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, p.Status, ParcelStatusDelivered)
+		}
+
+		if err := tx.fillDefaultAddress(number, ParcelStatusDelivered); err != nil {
+			return err
+		}
+
+		_, err = tx.db.Exec("UPDATE parcel SET status = ?, signed_by = ? WHERE number = ?",
+			ParcelStatusDelivered, sql.NullString{String: signedBy, Valid: signedBy != ""}, number)
+		return err
+	})
+}
+
+// GetRecentlyDelivered возвращает доставленные посылки, перешедшие в статус delivered
+// не раньше, чем within назад (по истории статусов), и ещё не отмеченные MarkSurveyed.
+// Используется для рассылки опроса после доставки.
+func (s ParcelStore) GetRecentlyDelivered(within time.Duration) (res []Parcel, err error) {
+	span := s.startSpan("GetRecentlyDelivered")
+	defer func() { endSpan(span, err) }()
+
+	since := formatTime(s.cfg.Now().Add(-within))
+
+	return s.queryParcels(`WHERE status = ? AND deleted_at IS NULL AND surveyed_at IS NULL
+		AND number IN (SELECT h.number FROM parcel_status_history h WHERE h.new_status = ? AND h.changed_at >= ?)
+		ORDER BY number`, ParcelStatusDelivered, ParcelStatusDelivered, since)
+}
+
+// MarkSurveyed отмечает, что получателю доставленной посылки отправлен опрос,
+// после чего посылка не возвращается GetRecentlyDelivered. Повторная отметка не меняет время.
+func (s ParcelStore) MarkSurveyed(number int64) (err error) {
+	span := s.startSpan("MarkSurveyed", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("mark surveyed", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.MarkSurveyed(number)
+		})
+		return err
+	}
+
+	res, err := s.db.Exec("UPDATE parcel SET surveyed_at = COALESCE(surveyed_at, ?) WHERE number = ? AND status = ? AND deleted_at IS NULL",
+		formatTime(s.cfg.Now()), number, ParcelStatusDelivered)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrParcelNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeliver проверяет доставку с подписью получателя
+func TestDeliver(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStoreWithConfig(db, StoreConfig{RequireSignature: true})
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	require.ErrorIs(t, store.Deliver(id, "Иванов И. И."), ErrInvalidTransition)
+
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	require.ErrorIs(t, store.SetStatus(id, ParcelStatusDelivered), ErrSignatureRequired)
+	require.ErrorIs(t, store.Deliver(id, " "), ErrSignatureRequired)
+
+	require.NoError(t, store.Deliver(id, "Иванов И. И."))
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusDelivered, stored.Status)
+	require.Equal(t, "Иванов И. И.", stored.SignedBy)
+
+	// без RequireSignature подпись необязательна
+	store = NewParcelStore(db)
+	id, err = store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	require.NoError(t, store.Deliver(id, ""))
+
+	stored, err = store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusDelivered, stored.Status)
+	require.Empty(t, stored.SignedBy)
+}
+
+// TestGetRecentlyDelivered проверяет выборку недавно доставленных посылок для опроса
+func TestGetRecentlyDelivered(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	delivered, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(delivered, ParcelStatusSent))
+	require.NoError(t, store.Deliver(delivered, ""))
+
+	sent, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(sent, ParcelStatusSent))
+
+	// check
+	parcels, err := store.GetRecentlyDelivered(time.Hour)
+	require.NoError(t, err)
+	numbers := make([]int64, 0, len(parcels))
+	for _, p := range parcels {
+		numbers = append(numbers, p.Number)
+	}
+	require.Contains(t, numbers, delivered)
+	require.NotContains(t, numbers, sent)
+
+	// доставка вне окна не попадает в выборку
+	later := NewParcelStoreWithConfig(db, StoreConfig{Now: func() time.Time { return time.Now().Add(2 * time.Hour) }})
+	parcels, err = later.GetRecentlyDelivered(time.Hour)
+	require.NoError(t, err)
+	for _, p := range parcels {
+		require.NotEqual(t, delivered, p.Number)
+	}
+
+	require.ErrorIs(t, store.MarkSurveyed(sent), ErrParcelNotFound)
+	require.NoError(t, store.MarkSurveyed(delivered))
+	require.NoError(t, store.MarkSurveyed(delivered))
+
+	parcels, err = store.GetRecentlyDelivered(time.Hour)
+	require.NoError(t, err)
+	for _, p := range parcels {
+		require.NotEqual(t, delivered, p.Number)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "go.opentelemetry.io/otel/attribute"
+
+// ParcelDetail посылка со всеми связанными записями для карточки посылки
+type ParcelDetail struct {
+	Parcel   Parcel
+	Tags     []string
+	History  []StatusChange
+	Attempts []DeliveryAttempt
+}
+
+// GetFull возвращает посылку вместе с метками, историей статусов и попытками доставки.
+// Всё читается в одной транзакции, так что части согласованы между собой.
+func (s ParcelStore) GetFull(number int64) (d ParcelDetail, err error) {
+	span := s.startSpan("GetFull", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		d.Parcel, err = tx.queryParcel("number = ? AND deleted_at IS NULL", number)
+		if err != nil {
+			return err
+		}
+		if d.Tags, err = tx.GetTags(number); err != nil {
+			return err
+		}
+		if d.History, err = tx.queryStatusChanges("WHERE number = ? ORDER BY changed_at, id", number); err != nil {
+			return err
+		}
+		d.Attempts, err = tx.GetAttempts(number)
+		return err
+	})
+	if err != nil {
+		return ParcelDetail{}, err
+	}
+
+	return d, nil
+}
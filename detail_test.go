@@ -0,0 +1,40 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetFull проверяет сборку карточки посылки
+func TestGetFull(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.AddTag(id, "urgent"))
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, ""))
+
+	// check
+	d, err := store.GetFull(id)
+	require.NoError(t, err)
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, stored, d.Parcel)
+	require.Equal(t, []string{"urgent"}, d.Tags)
+	require.Len(t, d.History, 2)
+	require.Equal(t, ParcelStatusSent, d.History[1].NewStatus)
+	require.Len(t, d.Attempts, 1)
+
+	_, err = store.GetFull(-1)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedDialect возвращается возможностями, которые есть не во всех диалектах
+var ErrUnsupportedDialect = errors.New("not supported by the SQL dialect")
+
+// Dialect диалект SQL базы, с которой работает ParcelStore
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// rebind переписывает плейсхолдеры "?" в синтаксис диалекта.
+// Для PostgreSQL это $1, $2, ...; "?" внутри строковых литералов не трогаются.
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRebind проверяет перевод плейсхолдеров в синтаксис диалекта
+func TestRebind(t *testing.T) {
+	query := `SELECT number FROM parcel WHERE client = ? AND external_ref LIKE ? ESCAPE '?' AND status = ?`
+
+	require.Equal(t, query, DialectSQLite.rebind(query))
+	require.Equal(t, `SELECT number FROM parcel WHERE client = $1 AND external_ref LIKE $2 ESCAPE '?' AND status = $3`,
+		DialectPostgres.rebind(query))
+}
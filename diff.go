@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// FieldChange поле посылки, значение которого отличается, со старым и новым значениями
+type FieldChange struct {
+	Field string // имя поля Parcel, например "Status"
+	Old   string
+	New   string
+}
+
+// Diff возвращает поля, которыми посылка b отличается от a, в порядке объявления полей Parcel.
+// Поля перебираются через reflect, поэтому новые поля Parcel попадают в сравнение сами.
+func Diff(a, b Parcel) []FieldChange {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+
+	var res []FieldChange
+	for i := 0; i < va.NumField(); i++ {
+		if va.Field(i).Equal(vb.Field(i)) {
+			continue
+		}
+		res = append(res, FieldChange{
+			Field: va.Type().Field(i).Name,
+			Old:   fmt.Sprint(va.Field(i).Interface()),
+			New:   fmt.Sprint(vb.Field(i).Interface()),
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDiff проверяет сравнение посылок по полям
+func TestDiff(t *testing.T) {
+	// prepare
+	before := getTestParcel()
+	after := before
+	after.Status = ParcelStatusSent
+	after.Priority = 3
+
+	// check
+	require.Empty(t, Diff(before, before))
+	require.Equal(t, []FieldChange{
+		{Field: "Status", Old: ParcelStatusRegistered, New: ParcelStatusSent},
+		{Field: "Priority", Old: "0", New: "3"},
+	}, Diff(before, after))
+
+	// каждое поле Parcel участвует в сравнении
+	typ := reflect.TypeOf(Parcel{})
+	for i := 0; i < typ.NumField(); i++ {
+		changed := before
+		field := reflect.ValueOf(&changed).Elem().Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(field.String() + "x")
+		case reflect.Int, reflect.Int64:
+			field.SetInt(field.Int() + 1)
+		case reflect.Bool:
+			field.SetBool(!field.Bool())
+		default:
+			t.Fatalf("field %s: unsupported kind %s", typ.Field(i).Name, field.Kind())
+		}
+
+		changes := Diff(before, changed)
+		require.Len(t, changes, 1)
+		require.Equal(t, typ.Field(i).Name, changes[0].Field)
+	}
+}
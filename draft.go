@@ -0,0 +1,127 @@
+package main
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Finalize переводит черновик в статус registered после полной проверки (Parcel.Validate).
+// Если поля не заполнены, возвращает ValidationErrors, из которой errors.As достаёт
+// *MissingFieldsError со списком полей;
+// адрес, равный StoreConfig.AddressPlaceholder, тоже считается незаполненным.
+func (s ParcelStore) Finalize(number int64) (err error) {
+	span := s.startSpan("Finalize", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("finalize", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.Finalize(number)
+		})
+		return err
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		p, err := tx.queryParcel("number = ? AND deleted_at IS NULL", number)
+		if err != nil {
+			return err
+		}
+		if p.Status != ParcelStatusDraft {
+			return ErrParcelNotDraft
+		}
+		if p.OnHold {
+			return ErrParcelOnHold
+		}
+
+		p.Status = ParcelStatusRegistered
+		if s.cfg.AddressPlaceholder != "" && p.Address == s.cfg.AddressPlaceholder {
+			p.Address = ""
+			p.AddressSet = false
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
+		_, err = tx.db.Exec("UPDATE parcel SET status = ? WHERE number = ? AND status = ?",
+			ParcelStatusRegistered, number, ParcelStatusDraft)
+		return err
+	})
+}
+
+// Reserve сразу выделяет клиенту номер посылки, например чтобы напечатать этикетку,
+// пока данные ещё вводятся: добавляет черновик без адреса со временем создания «сейчас».
+// Заполняется черновик через Complete, брошенные найдёт GetAbandonedReservations.
+func (s ParcelStore) Reserve(client int64) (number int64, err error) {
+	span := s.startSpan("Reserve", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	return s.Add(Parcel{Client: client, Status: ParcelStatusDraft, CreatedAt: formatTime(s.cfg.Now())})
+}
+
+// Complete заполняет зарезервированный Reserve черновик данными p и проверяет их, как Add.
+// Номер и клиент остаются от резервирования; пустой статус или draft становится registered,
+// пустой CreatedAt — временем резервирования. Если number не черновик, возвращает ErrParcelNotDraft.
+func (s ParcelStore) Complete(number int64, p Parcel) (err error) {
+	span := s.startSpan("Complete", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("complete", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.Complete(number, p)
+		})
+		return err
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		reserved, err := tx.GetForUpdate(number)
+		if err != nil {
+			return err
+		}
+		if reserved.Status != ParcelStatusDraft {
+			return ErrParcelNotDraft
+		}
+		if reserved.OnHold {
+			return ErrParcelOnHold
+		}
+
+		p.Number = number
+		p.Client = reserved.Client
+		if p.Status == "" || p.Status == ParcelStatusDraft {
+			p.Status = ParcelStatusRegistered
+		}
+		if p.CreatedAt == "" {
+			p.CreatedAt = reserved.CreatedAt
+		}
+
+		p, err = tx.prepare(p)
+		if err != nil {
+			return err
+		}
+		if p.City == "" {
+			p.City = cityFromAddress(p.Address)
+		}
+		if p.PublicID == "" && tx.cfg.IDGenerator != nil {
+			p.PublicID = tx.cfg.IDGenerator()
+		}
+
+		_, err = tx.db.Exec(`UPDATE parcel SET status = ?, address = ?, created_at = ?, external_ref = ?, city = ?, note = ?,
+			scheduled_at = ?, public_id = ?, client_code = ?, street = ?, postal_code = ?, country = ?, priority = ?, recipient_phone = ?,
+			recipient_name = ?
+			WHERE number = ?`,
+			p.Status, p.addressValue(), p.CreatedAt, nullString(p.ExternalRef), nullString(p.City), nullString(p.Note),
+			nullString(p.ScheduledAt), nullString(p.PublicID), nullString(p.ClientCode), nullString(p.Street),
+			nullString(p.PostalCode), nullString(p.Country), p.Priority, nullString(p.RecipientPhone), nullString(p.RecipientName), number)
+		return err
+	})
+}
+
+// GetAbandonedReservations возвращает черновики без адреса, созданные раньше, чем olderThan назад, —
+// номера, выделенные Reserve, но так и не заполненные Complete. Их можно удалить или напомнить клиенту.
+func (s ParcelStore) GetAbandonedReservations(olderThan time.Duration) (res []Parcel, err error) {
+	span := s.startSpan("GetAbandonedReservations")
+	defer func() { endSpan(span, err) }()
+
+	cond, cutoff := s.createdAtCond("<", s.cfg.Now().Add(-olderThan))
+	return s.queryParcels("WHERE status = ? AND address IS NULL AND created_at <> '' AND "+cond+" AND deleted_at IS NULL ORDER BY created_at, number",
+		ParcelStatusDraft, cutoff)
+}
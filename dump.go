@@ -0,0 +1,133 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// loadBatchSize сколько посылок LoadGzip добавляет одной транзакцией
+const loadBatchSize = 500
+
+// DumpGzip пишет в w все посылки, в том числе мягко удалённые, в формате JSON Lines (одна посылка на строку), сжатые gzip.
+// Строки читаются из БД курсором и сразу пишутся в w, весь набор в памяти не держится.
+func (s ParcelStore) DumpGzip(w io.Writer) (err error) {
+	span := s.startSpan("DumpGzip")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query("SELECT " + parcelColumns + " FROM parcel ORDER BY number")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	for rows.Next() {
+		p, err := scanParcel(rows)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// LoadGzip читает дамп, записанный DumpGzip, и вставляет посылки пачками по loadBatchSize
+// (не больше StoreConfig.MaxBatchSize), каждая пачка — отдельная транзакция. Возвращает количество вставленных посылок.
+// Посылки восстанавливаются как есть, со всеми колонками loadColumns: мягко удалённые остаются
+// удалёнными, задержанные — задержанными, незаданный адрес — NULL. Проверок и значений
+// по умолчанию Add нет, строки из дампа уже прошли их при записи.
+// Номера посылок назначаются заново, а колонок, которых нет в Parcel (например, surveyed_at),
+// в дампе нет и они не восстанавливаются. Грузить дамп нужно в другую базу: public_id уникален.
+func (s ParcelStore) LoadGzip(r io.Reader) (n int, err error) {
+	span := s.startSpan("LoadGzip")
+	defer func() { endSpan(span, err) }()
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	batchSize := loadBatchSize
+	if s.cfg.MaxBatchSize > 0 {
+		batchSize = min(batchSize, s.cfg.MaxBatchSize)
+	}
+
+	dec := json.NewDecoder(zr)
+	batch := make([]Parcel, 0, batchSize)
+	flush := func() error {
+		err := s.WithTx(func(tx ParcelStore) error {
+			for i, p := range batch {
+				if _, err := tx.loadParcel(p); err != nil {
+					return &ParcelError{Index: n + i, Op: "load", Err: err}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		n += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		var p Parcel
+		err := dec.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+
+		batch = append(batch, p)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// loadColumns колонки, которые LoadGzip восстанавливает из дампа: все parcelColumns, кроме number
+var loadColumns = strings.TrimPrefix(parcelColumns, "number, ")
+
+// loadParcel вставляет посылку из дампа в колонки loadColumns и возвращает её новый номер
+func (s ParcelStore) loadParcel(p Parcel) (int64, error) {
+	if s.tracksChanges() {
+		return s.audited("load", 0, func(tx ParcelStore) (int64, error) { return tx.loadParcel(p) })
+	}
+
+	args := []any{p.Client, p.Status, p.addressValue(), p.CreatedAt,
+		nullString(p.ExternalRef), nullString(p.City), nullString(p.Note), nullString(p.ScheduledAt), nullString(p.DeletedAt),
+		nullString(p.PublicID), nullString(p.SignedBy), nullString(p.ClientCode), nullString(p.ClaimedBy), nullString(p.Carrier),
+		nullString(p.Street), nullString(p.PostalCode), nullString(p.Country), p.Priority, p.OnHold, nullString(p.HoldReason),
+		nullString(p.RecipientPhone), nullString(p.RecipientName)}
+	res, err := s.db.Exec("INSERT INTO parcel ("+loadColumns+") VALUES ("+placeholders(len(args))+")", args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
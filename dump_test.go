@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDumpLoadGzip проверяет, что дамп загружается обратно без потери данных
+func TestDumpLoadGzip(t *testing.T) {
+	// prepare
+	store := newTempStore(t)
+	copyStore := newTempStore(t)
+
+	// add
+	parcel := getTestParcel()
+	parcel.ExternalRef = "dump"
+	parcel.Note = "хрупкое"
+	delivered, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetCarrier(delivered, "cdek"))
+	require.NoError(t, store.SetStatus(delivered, ParcelStatusSent))
+	require.NoError(t, store.Deliver(delivered, "Иванов"))
+
+	held, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.Hold(held, "проверка"))
+
+	deleted, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE parcel SET deleted_at = ?, claimed_by = ? WHERE number = ?", formatTime(time.Now()), "worker", deleted)
+	require.NoError(t, err)
+
+	// зарегистрированная посылка без адреса не проходит Validate, но должна загрузиться
+	noAddress, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE parcel SET address = NULL WHERE number = ?", noAddress)
+	require.NoError(t, err)
+
+	// dump
+	var buf bytes.Buffer
+	require.NoError(t, store.DumpGzip(&buf))
+
+	// load
+	n, err := copyStore.LoadGzip(&buf)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	// check
+	want, err := store.queryParcels("ORDER BY number")
+	require.NoError(t, err)
+	got, err := copyStore.queryParcels("ORDER BY number")
+	require.NoError(t, err)
+	require.Len(t, got, len(want))
+	for i := range want {
+		want[i].Number = 0
+		got[i].Number = 0
+		require.Equal(t, want[i], got[i])
+	}
+	require.NotEmpty(t, got[0].SignedBy)
+	require.True(t, got[1].OnHold)
+	require.NotEmpty(t, got[2].DeletedAt)
+	require.False(t, got[3].AddressSet)
+}
+
+// TestLoadGzipMaxBatchSize проверяет загрузку дампа больше StoreConfig.MaxBatchSize
+func TestLoadGzipMaxBatchSize(t *testing.T) {
+	// prepare
+	store := newTempStore(t)
+	copyStore := NewParcelStoreWithConfig(newTempStore(t).conn, StoreConfig{MaxBatchSize: 2})
+
+	// add
+	for i := 0; i < 5; i++ {
+		_, err := store.Add(getTestParcel())
+		require.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, store.DumpGzip(&buf))
+
+	// check
+	n, err := copyStore.LoadGzip(&buf)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+}
@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// Кроме created_at (RFC3339) время создания посылки хранится в колонке created_unix
+// как Unix-время в секундах. Колонку заполняют триггеры parcel_created_unix_insert
+// и parcel_created_unix_update (см. tracker.db), для старых строк её заполнила миграция 9.
+// Parcel.CreatedAt остаётся строкой, поэтому для вызывающего кода формат хранения не виден.
+
+// createdAtCond возвращает условие на время создания посылки op t (op — оператор сравнения)
+// и его параметр: по created_unix при StoreConfig.EpochTimestamps, иначе по created_at
+func (s ParcelStore) createdAtCond(op string, t time.Time) (string, any) {
+	if s.cfg.EpochTimestamps {
+		return "created_unix " + op + " ?", t.Unix()
+	}
+
+	return "created_at " + op + " ?", formatTime(t)
+}
+
+// GetCreatedBetween возвращает посылки, созданные на полуинтервале [from, to), в порядке создания
+func (s ParcelStore) GetCreatedBetween(from, to time.Time) (res []Parcel, err error) {
+	span := s.startSpan("GetCreatedBetween")
+	defer func() { endSpan(span, err) }()
+
+	fromCond, fromArg := s.createdAtCond(">=", from)
+	toCond, toArg := s.createdAtCond("<", to)
+
+	return s.queryParcels("WHERE "+fromCond+" AND "+toCond+" AND deleted_at IS NULL AND status <> ? ORDER BY created_at, number",
+		fromArg, toArg, ParcelStatusDraft)
+}
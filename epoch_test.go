@@ -0,0 +1,46 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetCreatedBetween проверяет выборку по диапазону дат в обоих форматах хранения времени
+func TestGetCreatedBetween(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(randRange.Int63n(10_000_000)) * time.Second)
+	parcel := getTestParcel()
+	parcel.CreatedAt = formatTime(created)
+
+	// add
+	id, err := NewParcelStore(db).Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	for _, epoch := range []bool{false, true} {
+		store := NewParcelStoreWithConfig(db, StoreConfig{EpochTimestamps: epoch})
+
+		parcels, err := store.GetCreatedBetween(created, created.Add(time.Second))
+		require.NoError(t, err)
+		require.Len(t, parcels, 1)
+		require.Equal(t, id, parcels[0].Number)
+		require.Equal(t, parcel.CreatedAt, parcels[0].CreatedAt)
+
+		parcels, err = store.GetCreatedBetween(created.Add(time.Second), created.Add(time.Hour))
+		require.NoError(t, err)
+		for _, p := range parcels {
+			require.NotEqual(t, id, p.Number)
+		}
+	}
+
+	var unix int64
+	require.NoError(t, db.QueryRow("SELECT created_unix FROM parcel WHERE number = ?", id).Scan(&unix))
+	require.Equal(t, created.Unix(), unix)
+}
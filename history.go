@@ -0,0 +1,321 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// История статусов пишется в таблицу parcel_status_history триггерами
+// parcel_status_history_insert и parcel_status_history_update (см. tracker.db):
+// каждая вставка и каждая смена статуса в parcel добавляет строку с временем изменения.
+// Поэтому история не зависит от того, каким методом поменяли статус.
+
+// StatusChange запись истории статусов посылки
+type StatusChange struct {
+	ID        int64
+	Number    int64
+	OldStatus string // пустая строка для первой записи, созданной при добавлении посылки
+	NewStatus string
+	ChangedAt string // RFC3339, UTC
+}
+
+// statusChangeColumns колонки parcel_status_history в порядке полей scanStatusChange
+const statusChangeColumns = "id, number, old_status, new_status, changed_at"
+
+// scanStatusChange читает запись истории, выбранную с колонками statusChangeColumns
+func scanStatusChange(row rowScanner) (StatusChange, error) {
+	var c StatusChange
+	var oldStatus sql.NullString
+
+	if err := row.Scan(&c.ID, &c.Number, &oldStatus, &c.NewStatus, &c.ChangedAt); err != nil {
+		return StatusChange{}, err
+	}
+	c.OldStatus = oldStatus.String
+
+	return c, nil
+}
+
+// queryStatusChanges читает записи истории, tail — часть запроса после FROM
+func (s ParcelStore) queryStatusChanges(tail string, args ...any) ([]StatusChange, error) {
+	rows, err := s.read.Query("SELECT "+statusChangeColumns+" FROM parcel_status_history "+tail, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []StatusChange
+	for rows.Next() {
+		c, err := scanStatusChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
+// GetTransitionsOnDate возвращает все смены статусов за календарный день day
+// (границы дня берутся в часовом поясе day) в порядке времени
+func (s ParcelStore) GetTransitionsOnDate(day time.Time) (res []StatusChange, err error) {
+	span := s.startSpan("GetTransitionsOnDate")
+	defer func() { endSpan(span, err) }()
+
+	y, m, d := day.Date()
+	from := time.Date(y, m, d, 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+
+	return s.queryStatusChanges("WHERE changed_at >= ? AND changed_at < ? ORDER BY changed_at, id",
+		formatTime(from), formatTime(to))
+}
+
+// StatusesSeen возвращает статусы, в которых побывала посылка, без повторов,
+// в порядке первого появления в истории статусов (GetFull отдаёт историю целиком).
+// Для посылки без истории возвращает пустой список.
+func (s ParcelStore) StatusesSeen(number int64) (statuses []string, err error) {
+	span := s.startSpan("StatusesSeen", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query("SELECT new_status FROM parcel_status_history WHERE number = ? GROUP BY new_status ORDER BY MIN(changed_at), MIN(id)", number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+// ExportHistoryCSV пишет в w все смены статусов на полуинтервале [from, to) в формате CSV
+// с заголовком number,old_status,new_status,changed_at, в порядке времени.
+// Строки читаются из БД курсором и сразу пишутся в w, поэтому период может быть любым.
+// Кто менял статус, история не хранит — это есть в журнале аудита (GetAuditByOperation).
+func (s ParcelStore) ExportHistoryCSV(from, to time.Time, w io.Writer) (err error) {
+	span := s.startSpan("ExportHistoryCSV")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query("SELECT "+statusChangeColumns+" FROM parcel_status_history WHERE changed_at >= ? AND changed_at < ? ORDER BY changed_at, id",
+		formatTime(from), formatTime(to))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"number", "old_status", "new_status", "changed_at"}); err != nil {
+		return err
+	}
+	for rows.Next() {
+		c, err := scanStatusChange(rows)
+		if err != nil {
+			return err
+		}
+		if err := cw.Write([]string{strconv.FormatInt(c.Number, 10), c.OldStatus, c.NewStatus, c.ChangedAt}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// CountDeliveredBetween возвращает количество переходов в статус delivered
+// на полуинтервале [from, to)
+func (s ParcelStore) CountDeliveredBetween(from, to time.Time) (n int, err error) {
+	span := s.startSpan("CountDeliveredBetween")
+	defer func() { endSpan(span, err) }()
+
+	row := s.read.QueryRow("SELECT COUNT(*) FROM parcel_status_history WHERE new_status = ? AND changed_at >= ? AND changed_at < ?",
+		ParcelStatusDelivered, formatTime(from), formatTime(to))
+	err = row.Scan(&n)
+
+	return n, err
+}
+
+// GetStuck возвращает посылки в статусе status, у которых последняя смена статуса
+// была раньше, чем olderThan назад. Для посылок без записей в истории
+// (добавленных до появления parcel_status_history) берётся created_at.
+func (s ParcelStore) GetStuck(status string, olderThan time.Duration) (res []Parcel, err error) {
+	span := s.startSpan("GetStuck")
+	defer func() { endSpan(span, err) }()
+
+	threshold := formatTime(s.cfg.Now().Add(-olderThan))
+
+	return s.queryParcels(`WHERE status = ? AND deleted_at IS NULL
+		AND COALESCE((SELECT MAX(h.changed_at) FROM parcel_status_history h WHERE h.number = parcel.number), created_at) < ?
+		ORDER BY number`, status, threshold)
+}
+
+// DeliveryRate возвращает долю доставленных посылок среди завершённых на полуинтервале [from, to):
+// delivered / (delivered + lost + returned) по переходам в эти статусы из истории.
+// Если завершённых посылок в окне нет, возвращает 0.
+func (s ParcelStore) DeliveryRate(from, to time.Time) (rate float64, err error) {
+	span := s.startSpan("DeliveryRate")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query("SELECT new_status, COUNT(*) FROM parcel_status_history WHERE new_status IN (?, ?, ?) AND changed_at >= ? AND changed_at < ? GROUP BY new_status",
+		ParcelStatusDelivered, ParcelStatusLost, ParcelStatusReturned, formatTime(from), formatTime(to))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var delivered, total int
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return 0, err
+		}
+		if status == ParcelStatusDelivered {
+			delivered = n
+		}
+		total += n
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if total == 0 {
+		return 0, nil
+	}
+
+	return float64(delivered) / float64(total), nil
+}
+
+// AuditHistoryConsistency возвращает номера посылок, текущий статус которых нельзя получить
+// по правилам переходов statusTransitions из последнего записанного в истории статуса,
+// например delivered при последней записи registered: смену статуса в обход истории.
+// Промежуточные шаги не проверяются: после Merge история двух посылок перемешана,
+// а RepairStatus записывает и недопустимые переходы.
+// Только диагностика, ничего не исправляет.
+func (s ParcelStore) AuditHistoryConsistency() (numbers []int64, err error) {
+	span := s.startSpan("AuditHistoryConsistency")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query(`SELECT p.number, h.new_status, p.status FROM parcel p
+		JOIN parcel_status_history h ON h.id = (SELECT last.id FROM parcel_status_history last
+			WHERE last.number = p.number ORDER BY last.changed_at DESC, last.id DESC LIMIT 1)
+		WHERE p.deleted_at IS NULL
+		ORDER BY p.number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var number int64
+		var last, current string
+		if err := rows.Scan(&number, &last, &current); err != nil {
+			return nil, err
+		}
+		if last != current && !CanTransition(last, current) {
+			numbers = append(numbers, number)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return numbers, nil
+}
+
+// FindTimestampAnomalies возвращает посылки с невозможными временами в истории статусов:
+// смена статуса раньше создания посылки (CreatedAt) или доставка раньше отправки
+// (запись delivered раньше записи sent). Мягко удалённые посылки не проверяются.
+// Только диагностика, ничего не исправляет.
+func (s ParcelStore) FindTimestampAnomalies() (res []Parcel, err error) {
+	span := s.startSpan("FindTimestampAnomalies")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels(`WHERE deleted_at IS NULL AND (
+		EXISTS (SELECT 1 FROM parcel_status_history h
+			WHERE h.number = parcel.number AND h.old_status IS NOT NULL AND h.changed_at < parcel.created_at)
+		OR EXISTS (SELECT 1 FROM parcel_status_history d JOIN parcel_status_history sent ON sent.number = d.number
+			WHERE d.number = parcel.number AND d.new_status = ? AND sent.new_status = ? AND d.changed_at < sent.changed_at)
+		) ORDER BY number`, ParcelStatusDelivered, ParcelStatusSent)
+}
+
+// ChurningParcel посылка с количеством записей в истории статусов
+type ChurningParcel struct {
+	Parcel  Parcel
+	Changes int
+}
+
+// countScanner дочитывает после колонок parcelColumns колонку с количеством
+type countScanner struct {
+	row   rowScanner
+	count *int
+}
+
+func (c countScanner) Scan(dest ...any) error {
+	return c.row.Scan(append(dest, c.count)...)
+}
+
+// GetChurningParcels возвращает посылки, у которых в истории статусов больше minChanges записей
+// (включая запись о создании), — кандидатов на разбор. Сначала идут самые «прыгающие».
+func (s ParcelStore) GetChurningParcels(minChanges int) (res []ChurningParcel, err error) {
+	span := s.startSpan("GetChurningParcels")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query(`SELECT `+parcelColumns+`, c.changes FROM parcel
+		JOIN (SELECT number AS changed_number, COUNT(*) AS changes FROM parcel_status_history
+			GROUP BY number HAVING COUNT(*) > ?) c ON c.changed_number = parcel.number
+		WHERE deleted_at IS NULL
+		ORDER BY c.changes DESC, number`, minChanges)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c ChurningParcel
+		if c.Parcel, err = scanParcel(countScanner{row: rows, count: &c.Changes}); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
+// BackfillHistory добавляет начальную запись истории (из пустого статуса в текущий,
+// со временем CreatedAt) каждой посылке, у которой истории нет, — например добавленной
+// до появления parcel_status_history. Возвращает количество дополненных посылок.
+// Посылки с историей не затрагиваются, поэтому повторный вызов ничего не меняет.
+func (s ParcelStore) BackfillHistory() (n int, err error) {
+	span := s.startSpan("BackfillHistory")
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.Exec(`INSERT INTO parcel_status_history (number, old_status, new_status, changed_at)
+		SELECT number, NULL, status, created_at FROM parcel
+		WHERE NOT EXISTS (SELECT 1 FROM parcel_status_history h WHERE h.number = parcel.number)`)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+
+	return int(affected), err
+}
+
+// formatTime приводит время к формату, в котором оно хранится в БД (RFC3339, UTC),
+// такие строки можно сравнивать в запросах как обычный текст
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCountDeliveredBetween проверяет подсчёт доставленных посылок за период
+func TestCountDeliveredBetween(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	from := time.Now().Add(-time.Minute)
+	to := time.Now().Add(time.Minute)
+
+	before, err := store.CountDeliveredBetween(from, to)
+	require.NoError(t, err)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+
+	// deliver
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	require.NoError(t, store.SetStatus(id, ParcelStatusDelivered))
+
+	// check
+	after, err := store.CountDeliveredBetween(from, to)
+	require.NoError(t, err)
+	require.Equal(t, before+1, after)
+
+	future, err := store.CountDeliveredBetween(to, to.Add(time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, future)
+}
+
+// TestGetTransitionsOnDate проверяет выборку смен статусов за день
+func TestGetTransitionsOnDate(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	changes, err := store.GetTransitionsOnDate(time.Now().UTC())
+	require.NoError(t, err)
+
+	var own []StatusChange
+	for i, c := range changes {
+		if i > 0 {
+			require.LessOrEqual(t, changes[i-1].ChangedAt, c.ChangedAt)
+		}
+		if c.Number == id {
+			own = append(own, c)
+		}
+	}
+	require.Len(t, own, 2)
+	require.Equal(t, "", own[0].OldStatus)
+	require.Equal(t, ParcelStatusRegistered, own[0].NewStatus)
+	require.Equal(t, ParcelStatusRegistered, own[1].OldStatus)
+	require.Equal(t, ParcelStatusSent, own[1].NewStatus)
+
+	// в общей базе могут быть чужие посылки из будущего, проверяются только свои
+	changes, err = store.GetTransitionsOnDate(time.Now().UTC().AddDate(0, 0, 1))
+	require.NoError(t, err)
+	for _, c := range changes {
+		require.NotEqual(t, id, c.Number)
+	}
+}
+
+// TestStatusesSeen проверяет список статусов посылки без повторов в порядке появления
+func TestStatusesSeen(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	require.NoError(t, store.RepairStatus(id, ParcelStatusRegistered))
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	statuses, err := store.StatusesSeen(id)
+	require.NoError(t, err)
+	require.Equal(t, []string{ParcelStatusRegistered, ParcelStatusSent}, statuses)
+
+	statuses, err = store.StatusesSeen(-1)
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+}
+
+// TestExportHistoryCSV проверяет выгрузку истории статусов за период в CSV
+func TestExportHistoryCSV(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	var buf bytes.Buffer
+	now := time.Now().UTC()
+	require.NoError(t, store.ExportHistoryCSV(now.Add(-time.Hour), now.Add(time.Hour), &buf))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"number", "old_status", "new_status", "changed_at"}, records[0])
+
+	var own [][]string
+	for _, r := range records[1:] {
+		if r[0] == strconv.FormatInt(id, 10) {
+			own = append(own, r)
+		}
+	}
+	require.Len(t, own, 2)
+	require.Equal(t, []string{"", ParcelStatusRegistered}, own[0][1:3])
+	require.Equal(t, []string{ParcelStatusRegistered, ParcelStatusSent}, own[1][1:3])
+
+	buf.Reset()
+	require.NoError(t, store.ExportHistoryCSV(now.Add(time.Hour), now.Add(2*time.Hour), &buf))
+	require.Equal(t, "number,old_status,new_status,changed_at\n", buf.String())
+}
+
+// TestGetStuck проверяет выборку посылок, давно не менявших статус
+func TestGetStuck(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	contains := func(status string, store ParcelStore) bool {
+		stuck, err := store.GetStuck(status, 24*time.Hour)
+		require.NoError(t, err)
+		for _, p := range stuck {
+			if p.Number == id {
+				return true
+			}
+		}
+		return false
+	}
+	require.False(t, contains(ParcelStatusSent, store))
+
+	// через две недели посылка считается застрявшей
+	later := NewParcelStoreWithConfig(db, StoreConfig{Now: func() time.Time {
+		return time.Now().Add(14 * 24 * time.Hour)
+	}})
+	require.True(t, contains(ParcelStatusSent, later))
+	require.False(t, contains(ParcelStatusRegistered, later))
+}
+
+// TestDeliveryRate проверяет долю доставленных среди завершённых посылок
+func TestDeliveryRate(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	from := time.Now().Add(-time.Minute)
+
+	// add
+	for _, status := range []string{ParcelStatusDelivered, ParcelStatusDelivered, ParcelStatusLost, ParcelStatusReturned} {
+		id, err := store.Add(getTestParcel())
+		require.NoError(t, err)
+		require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+		require.NoError(t, store.SetStatus(id, status))
+	}
+
+	// check
+	rate, err := store.DeliveryRate(from, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Greater(t, rate, 0.0)
+	require.Less(t, rate, 1.0)
+
+	// окно в будущем пустое
+	rate, err = store.DeliveryRate(time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 0.0, rate)
+}
+
+// TestAuditHistoryConsistency проверяет поиск посылок с нарушенной историей статусов
+func TestAuditHistoryConsistency(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	valid, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(valid, ParcelStatusSent))
+
+	// RepairStatus пропускает sent, но записывает переход в историю
+	repaired, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.RepairStatus(repaired, ParcelStatusDelivered))
+
+	// статус сменили в обход истории: последняя запись — registered
+	skipped, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.RepairStatus(skipped, ParcelStatusDelivered))
+	_, err = db.Exec("DELETE FROM parcel_status_history WHERE number = ? AND new_status = ?", skipped, ParcelStatusDelivered)
+	require.NoError(t, err)
+
+	// после Merge история discard оказывается среди истории keep
+	discard, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(discard, ParcelStatusSent))
+	keep, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(keep, ParcelStatusSent))
+	require.NoError(t, store.Deliver(keep, "Иванов"))
+	require.NoError(t, store.Merge(keep, discard))
+
+	// check
+	numbers, err := store.AuditHistoryConsistency()
+	require.NoError(t, err)
+	require.Contains(t, numbers, skipped)
+	require.NotContains(t, numbers, valid)
+	require.NotContains(t, numbers, repaired)
+	require.NotContains(t, numbers, keep)
+}
+
+// TestBackfillHistory проверяет восстановление начальной записи истории
+func TestBackfillHistory(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// посылка из времён до истории статусов
+	_, err = db.Exec("DELETE FROM parcel_status_history WHERE number = ?", id)
+	require.NoError(t, err)
+
+	// check
+	n, err := store.BackfillHistory()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 1)
+
+	history, err := store.queryStatusChanges("WHERE number = ?", id)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Empty(t, history[0].OldStatus)
+	require.Equal(t, ParcelStatusSent, history[0].NewStatus)
+	require.Equal(t, parcel.CreatedAt, history[0].ChangedAt)
+
+	n, err = store.BackfillHistory()
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+// TestGetChurningParcels проверяет поиск посылок с частой сменой статусов
+func TestGetChurningParcels(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	churning, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.SetStatus(churning, ParcelStatusSent))
+		require.NoError(t, store.RepairStatus(churning, ParcelStatusRegistered))
+	}
+
+	calm, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(calm, ParcelStatusSent))
+
+	// check
+	parcels, err := store.GetChurningParcels(5)
+	require.NoError(t, err)
+
+	changes := map[int64]int{}
+	for _, c := range parcels {
+		changes[c.Parcel.Number] = c.Changes
+	}
+	require.Equal(t, 7, changes[churning])
+	require.NotContains(t, changes, calm)
+}
+
+// TestFindTimestampAnomalies проверяет поиск посылок с невозможными временами в истории
+func TestFindTimestampAnomalies(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	valid, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(valid, ParcelStatusSent))
+	require.NoError(t, store.SetStatus(valid, ParcelStatusDelivered))
+
+	// время создания чуть впереди часов сервера, статус сменился «раньше» создания
+	ahead := getTestParcel()
+	ahead.CreatedAt = formatTime(time.Now().Add(2 * time.Minute))
+	changedEarly, err := store.Add(ahead)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(changedEarly, ParcelStatusSent))
+
+	// доставка записана раньше отправки, например после импорта
+	deliveredEarly, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(deliveredEarly, ParcelStatusSent))
+	require.NoError(t, store.SetStatus(deliveredEarly, ParcelStatusDelivered))
+	_, err = db.Exec("UPDATE parcel_status_history SET changed_at = ? WHERE number = ? AND new_status = ?",
+		formatTime(time.Now().Add(time.Hour)), deliveredEarly, ParcelStatusSent)
+	require.NoError(t, err)
+
+	// check
+	parcels, err := store.FindTimestampAnomalies()
+	require.NoError(t, err)
+	numbers := make([]int64, 0, len(parcels))
+	for _, p := range parcels {
+		numbers = append(numbers, p.Number)
+	}
+	require.Contains(t, numbers, changedEarly)
+	require.Contains(t, numbers, deliveredEarly)
+	require.NotContains(t, numbers, valid)
+}
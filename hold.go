@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrParcelOnHold посылка задержана Hold, её статус и адрес менять нельзя до Release
+	ErrParcelOnHold    = errors.New("parcel is on hold")
+	ErrEmptyHoldReason = errors.New("hold reason is empty")
+)
+
+// Hold задерживает посылку, например по требованию юристов: пока не вызван Release,
+// SetStatus, SetAddress, Deliver, Finalize, RepairStatus, Delete, Archive и Merge возвращают ErrParcelOnHold,
+// а массовые операции (AdvanceDuePickups, ClaimNextRegistered, PurgeOlderThan, DeduplicateClient)
+// её пропускают. Повторный Hold меняет причину.
+func (s ParcelStore) Hold(number int64, reason string) (err error) {
+	span := s.startSpan("Hold", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("hold", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.Hold(number, reason)
+		})
+		return err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return ErrEmptyHoldReason
+	}
+
+	return s.setHold(number, true, sql.NullString{String: reason, Valid: true})
+}
+
+// Release снимает задержку, установленную Hold. Посылку без задержки Release не меняет.
+func (s ParcelStore) Release(number int64) (err error) {
+	span := s.startSpan("Release", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("release", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.Release(number)
+		})
+		return err
+	}
+
+	return s.setHold(number, false, sql.NullString{})
+}
+
+// setHold записывает признак и причину задержки посылки
+func (s ParcelStore) setHold(number int64, onHold bool, reason sql.NullString) error {
+	res, err := s.db.Exec("UPDATE parcel SET on_hold = ?, hold_reason = ? WHERE number = ? AND deleted_at IS NULL", onHold, reason, number)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrParcelNotFound
+	}
+
+	return nil
+}
+
+// checkNotOnHold возвращает ErrParcelOnHold, если посылка задержана.
+// Отсутствие посылки здесь не ошибка: его обрабатывает сам вызывающий метод.
+func (s ParcelStore) checkNotOnHold(number int64) error {
+	var onHold bool
+	err := s.db.QueryRow("SELECT on_hold FROM parcel WHERE number = ? AND deleted_at IS NULL", number).Scan(&onHold)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if onHold {
+		return ErrParcelOnHold
+	}
+
+	return nil
+}
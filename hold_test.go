@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHold проверяет задержку посылки и её снятие
+func TestHold(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	require.ErrorIs(t, store.Hold(id, " "), ErrEmptyHoldReason)
+	require.ErrorIs(t, store.Hold(-1, "legal"), ErrParcelNotFound)
+	require.NoError(t, store.Hold(id, " legal "))
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.True(t, stored.OnHold)
+	require.Equal(t, "legal", stored.HoldReason)
+
+	require.ErrorIs(t, store.SetStatus(id, ParcelStatusSent), ErrParcelOnHold)
+	require.ErrorIs(t, store.SetAddress(id, "new address"), ErrParcelOnHold)
+	require.ErrorIs(t, store.Delete(id), ErrParcelOnHold)
+	require.ErrorIs(t, store.RepairStatus(id, ParcelStatusLost), ErrParcelOnHold)
+
+	err = store.ApplyStatuses(map[int64]string{id: ParcelStatusSent})
+	var applyErr *ApplyStatusesError
+	require.ErrorAs(t, err, &applyErr)
+	require.ErrorIs(t, err, ErrParcelOnHold)
+
+	require.NoError(t, store.Release(id))
+	stored, err = store.Get(id)
+	require.NoError(t, err)
+	require.False(t, stored.OnHold)
+	require.Empty(t, stored.HoldReason)
+
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	require.NoError(t, store.Hold(id, "legal"))
+	require.ErrorIs(t, store.Deliver(id, "Иванов"), ErrParcelOnHold)
+
+	// задержанную посылку нельзя убрать из parcel
+	delivered, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.RepairStatus(delivered, ParcelStatusDelivered))
+	require.NoError(t, store.Hold(delivered, "legal"))
+	require.ErrorIs(t, store.Archive(delivered), ErrParcelOnHold)
+	_, err = store.Get(delivered)
+	require.NoError(t, err)
+}
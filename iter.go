@@ -0,0 +1,47 @@
+//go:build go1.23
+
+package main
+
+import (
+	"iter"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AllByClient возвращает итератор по посылкам клиента, черновики и удалённые посылки не входят:
+//
+//	for p, err := range store.AllByClient(client) { ... }
+//
+// Строки читаются из БД по мере обхода, при досрочном выходе из цикла курсор закрывается.
+// Ошибка запроса или чтения строки приходит последней парой с пустой посылкой.
+func (s ParcelStore) AllByClient(client int64) iter.Seq2[Parcel, error] {
+	return func(yield func(Parcel, error) bool) {
+		var err error
+		span := s.startSpan("AllByClient", attribute.Int64(attrParcelClient, client))
+		defer func() { endSpan(span, err) }()
+
+		rows, err := s.read.Query("SELECT "+parcelColumns+" FROM parcel WHERE client = ? AND status <> ? AND deleted_at IS NULL ORDER BY number",
+			client, ParcelStatusDraft)
+		if err != nil {
+			yield(Parcel{}, err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p Parcel
+			p, err = scanParcel(rows)
+			if err != nil {
+				yield(Parcel{}, err)
+				return
+			}
+			if !yield(p, nil) {
+				return
+			}
+		}
+
+		if err = rows.Err(); err != nil {
+			yield(Parcel{}, err)
+		}
+	}
+}
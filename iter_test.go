@@ -0,0 +1,48 @@
+//go:build go1.23
+
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestAllByClient проверяет обход посылок клиента итератором и досрочный выход из цикла
+func TestAllByClient(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	client := randRange.Int63n(10_000_000)
+
+	// add
+	var numbers []int64
+	for i := 0; i < 3; i++ {
+		parcel := getTestParcel()
+		parcel.Client = client
+		id, err := store.Add(parcel)
+		require.NoError(t, err)
+		numbers = append(numbers, id)
+	}
+
+	// check
+	var got []int64
+	for p, err := range store.AllByClient(client) {
+		require.NoError(t, err)
+		got = append(got, p.Number)
+	}
+	require.Equal(t, numbers, got)
+
+	// досрочный выход должен закрыть курсор и освободить соединение для следующих запросов
+	for p, err := range store.AllByClient(client) {
+		require.NoError(t, err)
+		require.Equal(t, numbers[0], p.Number)
+		break
+	}
+	_, err = store.Add(getTestParcel())
+	require.NoError(t, err)
+}
@@ -12,25 +12,52 @@ const (
 	ParcelStatusRegistered = "registered"
 	ParcelStatusSent       = "sent"
 	ParcelStatusDelivered  = "delivered"
+	// ParcelStatusProcessing посылку взял в обработку обработчик, см. ParcelStore.ClaimNextRegistered
+	ParcelStatusProcessing = "processing"
+	// ParcelStatusLost посылка утеряна в пути
+	ParcelStatusLost = "lost"
+	// ParcelStatusReturned посылка возвращена отправителю
+	ParcelStatusReturned = "returned"
+	// ParcelStatusDraft черновик: данные ещё не заполнены, посылка ждёт ParcelStore.Finalize
+	ParcelStatusDraft = "draft"
 )
 
 type Parcel struct {
-	Number    int
-	Client    int
-	Status    string
-	Address   string
-	CreatedAt string
+	Number         int64
+	Client         int64
+	Status         string
+	Address        string
+	AddressSet     bool // адрес задан, пусть и пустой; незаданный пустой адрес хранится в БД как NULL
+	CreatedAt      string
+	ExternalRef    string // пустая строка хранится в БД как NULL
+	City           string // если не задан, Add берёт его из адреса
+	Note           string // пустая строка хранится в БД как NULL
+	ScheduledAt    string // время забора посылки курьером (RFC3339, хранится в UTC), пустая строка — не назначено
+	DeletedAt      string // время мягкого удаления (RFC3339), пустая строка — посылка не удалена
+	PublicID       string // внешний идентификатор, если задан StoreConfig.IDGenerator; пустая строка хранится как NULL
+	SignedBy       string // кто расписался в получении, задаётся ParcelStore.Deliver
+	ClientCode     string // код клиента во внешней системе партнёра, пустая строка хранится как NULL
+	ClaimedBy      string // обработчик, взявший посылку через ParcelStore.ClaimNextRegistered
+	Carrier        string // курьерская служба последней мили, задаётся ParcelStore.SetCarrier
+	Street         string // улица и дом; вместе с City, PostalCode и Country — адрес по частям
+	PostalCode     string
+	Country        string
+	Priority       int    // срочность обработки, чем больше, тем срочнее; по умолчанию 0
+	OnHold         bool   // посылка задержана ParcelStore.Hold, статус и адрес менять нельзя
+	HoldReason     string // причина задержки, пустая строка, если посылка не задержана
+	RecipientPhone string // телефон получателя, хранится только цифрами и «+», см. normalizePhone
+	RecipientName  string // имя получателя, пустая строка хранится в БД как NULL
 }
 
 type ParcelService struct {
-	store ParcelStore
+	store Store
 }
 
-func NewParcelService(store ParcelStore) ParcelService {
+func NewParcelService(store Store) ParcelService {
 	return ParcelService{store: store}
 }
 
-func (s ParcelService) Register(client int, address string) (Parcel, error) {
+func (s ParcelService) Register(client int64, address string) (Parcel, error) {
 	parcel := Parcel{
 		Client:    client,
 		Status:    ParcelStatusRegistered,
@@ -51,7 +78,7 @@ func (s ParcelService) Register(client int, address string) (Parcel, error) {
 	return parcel, nil
 }
 
-func (s ParcelService) PrintClientParcels(client int) error {
+func (s ParcelService) PrintClientParcels(client int64) error {
 	parcels, err := s.store.GetByClient(client)
 	if err != nil {
 		return err
@@ -67,7 +94,7 @@ func (s ParcelService) PrintClientParcels(client int) error {
 	return nil
 }
 
-func (s ParcelService) NextStatus(number int) error {
+func (s ParcelService) NextStatus(number int64) error {
 	parcel, err := s.store.Get(number)
 	if err != nil {
 		return err
@@ -77,10 +104,14 @@ func (s ParcelService) NextStatus(number int) error {
 	switch parcel.Status {
 	case ParcelStatusRegistered:
 		nextStatus = ParcelStatusSent
+	case ParcelStatusProcessing:
+		nextStatus = ParcelStatusSent
 	case ParcelStatusSent:
 		nextStatus = ParcelStatusDelivered
-	case ParcelStatusDelivered:
+	case ParcelStatusDelivered, ParcelStatusLost, ParcelStatusReturned:
 		return nil
+	case ParcelStatusDraft:
+		return ErrParcelIsDraft
 	}
 
 	fmt.Printf("У посылки № %d новый статус: %s\n", number, nextStatus)
@@ -88,11 +119,11 @@ func (s ParcelService) NextStatus(number int) error {
 	return s.store.SetStatus(number, nextStatus)
 }
 
-func (s ParcelService) ChangeAddress(number int, address string) error {
+func (s ParcelService) ChangeAddress(number int64, address string) error {
 	return s.store.SetAddress(number, address)
 }
 
-func (s ParcelService) Delete(number int) error {
+func (s ParcelService) Delete(number int64) error {
 	return s.store.Delete(number)
 }
 
@@ -103,7 +134,7 @@ func main() {
 	service := NewParcelService(store)
 
 	// регистрация посылки
-	client := 1
+	client := int64(1)
 	address := "Псков, д. Пушкина, ул. Колотушкина, д. 5"
 	p, err := service.Register(client, address)
 	if err != nil {
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ManifestItem посылка в манифесте передачи перевозчику
+type ManifestItem struct {
+	Number  int64
+	Address string
+}
+
+// Manifest манифест передачи посылок клиента перевозчику
+type Manifest struct {
+	Client      int64
+	Items       []ManifestItem // в порядке номеров
+	GeneratedAt string         // RFC3339, UTC; в Hash не входит
+	Hash        string         // SHA-256 в hex от Client и Items, см. Verify
+}
+
+// GenerateManifest собирает манифест из активных посылок клиента: не черновиков,
+// не удалённых и не в конечном статусе. Hash зависит только от клиента и посылок,
+// поэтому манифесты с одинаковым содержимым имеют одинаковый Hash.
+func (s ParcelStore) GenerateManifest(client int64) (m Manifest, err error) {
+	span := s.startSpan("GenerateManifest", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	terminal := terminalStatuses()
+	args := append([]any{client, ParcelStatusDraft}, terminal...)
+	parcels, err := s.queryParcels("WHERE client = ? AND status <> ? AND status NOT IN ("+placeholders(len(terminal))+") AND deleted_at IS NULL ORDER BY number", args...)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	m = Manifest{Client: client, Items: make([]ManifestItem, len(parcels)), GeneratedAt: formatTime(s.cfg.Now())}
+	for i, p := range parcels {
+		m.Items[i] = ManifestItem{Number: p.Number, Address: p.Address}
+	}
+	if m.Hash, err = m.contentHash(); err != nil {
+		return Manifest{}, err
+	}
+
+	return m, nil
+}
+
+// Verify сообщает, что содержимое манифеста не менялось после GenerateManifest
+func (m Manifest) Verify() bool {
+	hash, err := m.contentHash()
+	return err == nil && hash == m.Hash
+}
+
+// contentHash считает Hash манифеста по JSON клиента и посылок
+func (m Manifest) contentHash() (string, error) {
+	data, err := json.Marshal(struct {
+		Client int64
+		Items  []ManifestItem
+	}{m.Client, m.Items})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
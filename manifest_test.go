@@ -0,0 +1,51 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerateManifest проверяет состав манифеста и его хеш
+func TestGenerateManifest(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := NewParcelStore(db, WithClock(func() time.Time { return now }))
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+	parcel.CreatedAt = formatTime(now)
+
+	// add
+	first, err := store.Add(parcel)
+	require.NoError(t, err)
+	second, err := store.Add(parcel)
+	require.NoError(t, err)
+	delivered, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(delivered, ParcelStatusSent))
+	require.NoError(t, store.SetStatus(delivered, ParcelStatusDelivered))
+
+	// check
+	m, err := store.GenerateManifest(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, parcel.Client, m.Client)
+	require.Equal(t, []ManifestItem{{Number: first, Address: parcel.Address}, {Number: second, Address: parcel.Address}}, m.Items)
+	require.Equal(t, "2024-01-02T03:04:05Z", m.GeneratedAt)
+	require.Len(t, m.Hash, 64)
+	require.True(t, m.Verify())
+
+	// то же содержимое — тот же хеш, независимо от времени
+	now = now.Add(time.Hour)
+	again, err := store.GenerateManifest(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, m.Hash, again.Hash)
+
+	m.Items[0].Address = "other"
+	require.False(t, m.Verify())
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrMergeSameParcel     = errors.New("cannot merge a parcel into itself")
+	ErrMergeClientMismatch = errors.New("cannot merge parcels of different clients")
+)
+
+// Merge сливает посылку discard в посылку keep: история статусов, попытки доставки и метки discard
+// переносятся на keep, комментарий discard дописывается к комментарию keep, а сама discard мягко удаляется.
+// Сливать можно только разные посылки одного клиента.
+func (s ParcelStore) Merge(keep, discard int64) (err error) {
+	span := s.startSpan("Merge", attribute.Int64(attrParcelNumber, keep), attribute.Int64("parcel.discard", discard))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		// в журнал попадают обе посылки: keep с новым комментарием и удалённая discard
+		_, err = s.audited("merge", keep, func(tx ParcelStore) (int64, error) {
+			_, err := tx.audited("merge", discard, func(tx ParcelStore) (int64, error) {
+				return discard, tx.Merge(keep, discard)
+			})
+			return keep, err
+		})
+		return err
+	}
+
+	if keep == discard {
+		return ErrMergeSameParcel
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		kept, err := tx.queryParcel("number = ? AND deleted_at IS NULL", keep)
+		if err != nil {
+			return err
+		}
+		discarded, err := tx.queryParcel("number = ? AND deleted_at IS NULL", discard)
+		if err != nil {
+			return err
+		}
+		if kept.Client != discarded.Client {
+			return ErrMergeClientMismatch
+		}
+		if discarded.OnHold {
+			return ErrParcelOnHold
+		}
+
+		if _, err := tx.db.Exec("UPDATE parcel_status_history SET number = ? WHERE number = ?", keep, discard); err != nil {
+			return err
+		}
+		if _, err := tx.db.Exec("UPDATE parcel_delivery_attempt SET number = ? WHERE number = ?", keep, discard); err != nil {
+			return err
+		}
+		if _, err := tx.db.Exec("INSERT INTO parcel_tag (number, tag) SELECT ?, tag FROM parcel_tag WHERE number = ? ON CONFLICT DO NOTHING", keep, discard); err != nil {
+			return err
+		}
+		if _, err := tx.db.Exec("DELETE FROM parcel_tag WHERE number = ?", discard); err != nil {
+			return err
+		}
+
+		if discarded.Note != "" {
+			note := discarded.Note
+			if kept.Note != "" {
+				note = kept.Note + "\n" + discarded.Note
+			}
+			if _, err := tx.db.Exec("UPDATE parcel SET note = ? WHERE number = ?", note, keep); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.db.Exec("UPDATE parcel SET deleted_at = ? WHERE number = ?", formatTime(tx.cfg.Now()), discard)
+		return err
+	})
+}
+
+// DeduplicateClient убирает повторно оформленные посылки клиента: среди посылок с одинаковым адресом
+// (после StoreConfig.Normalize) и днём создания остаётся самая ранняя, остальные мягко удаляются, а связь
+// «дубль → оставленная посылка» записывается в parcel_duplicate. ExternalRef не сравнивается:
+// у повторной отправки он может отличаться или отсутствовать. Удаляются только дубли в статусе registered,
+// посылки без адреса, черновики и задержанные Hold не трогаются. Всё выполняется в одной транзакции.
+func (s ParcelStore) DeduplicateClient(client int64) (removed int, err error) {
+	span := s.startSpan("DeduplicateClient", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		removed = 0
+
+		parcels, err := tx.queryParcels("WHERE client = ? AND status <> ? AND on_hold = 0 AND deleted_at IS NULL AND address IS NOT NULL AND address <> '' ORDER BY created_at, number",
+			client, ParcelStatusDraft)
+		if err != nil {
+			return err
+		}
+
+		now := formatTime(tx.cfg.Now())
+		kept := make(map[string]int64)
+		for _, p := range parcels {
+			key := tx.normalizeAddress(p.Address) + "\x00" + createdDay(p.CreatedAt)
+			original, ok := kept[key]
+			if !ok {
+				kept[key] = p.Number
+				continue
+			}
+			if p.Status != ParcelStatusRegistered {
+				continue
+			}
+
+			if err := tx.markDuplicate(p.Number, original, now); err != nil {
+				return err
+			}
+			removed++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return removed, nil
+}
+
+// markDuplicate мягко удаляет посылку number как дубль посылки original и записывает связь в parcel_duplicate
+func (s ParcelStore) markDuplicate(number, original int64, now string) error {
+	if s.tracksChanges() {
+		_, err := s.audited("deduplicate", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.markDuplicate(number, original, now)
+		})
+		return err
+	}
+
+	if _, err := s.db.Exec("UPDATE parcel SET deleted_at = ? WHERE number = ?", now, number); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("INSERT INTO parcel_duplicate (number, duplicate_of, created_at) VALUES (?, ?, ?)", number, original, now)
+	return err
+}
+
+// createdDay возвращает дату (YYYY-MM-DD по UTC) из CreatedAt.
+// Нераспознанное время возвращается как есть, такие посылки совпадают только с точно таким же.
+func createdDay(createdAt string) string {
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return createdAt
+	}
+	return t.UTC().Format("2006-01-02")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMerge проверяет слияние двух посылок клиента
+func TestMerge(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+
+	// add
+	keep, err := store.Add(parcel)
+	require.NoError(t, err)
+	parcel.Note = "позвонить заранее"
+	discard, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.AddTag(keep, "fragile"))
+	require.NoError(t, store.AddTag(discard, "fragile"))
+	require.NoError(t, store.AddTag(discard, "urgent"))
+
+	other := getTestParcel()
+	otherID, err := store.Add(other)
+	require.NoError(t, err)
+
+	// guards
+	require.ErrorIs(t, store.Merge(keep, keep), ErrMergeSameParcel)
+	require.ErrorIs(t, store.Merge(keep, otherID), ErrMergeClientMismatch)
+
+	// merge
+	require.NoError(t, store.Merge(keep, discard))
+
+	// check
+	_, err = store.Get(discard)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	parcels, err := store.Query(QueryOptions{Client: parcel.Client})
+	require.NoError(t, err)
+	require.Len(t, parcels, 1)
+	require.Equal(t, keep, parcels[0].Number)
+	require.Equal(t, "позвонить заранее", parcels[0].Note)
+
+	tags, err := store.GetTags(keep)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fragile", "urgent"}, tags)
+
+	var moved int
+	err = db.QueryRow("SELECT COUNT(*) FROM parcel_status_history WHERE number = ?", keep).Scan(&moved)
+	require.NoError(t, err)
+	require.Equal(t, 2, moved)
+}
+
+// TestDeduplicateClient проверяет удаление повторно оформленных посылок клиента
+func TestDeduplicateClient(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+
+	// add
+	first, err := store.Add(parcel)
+	require.NoError(t, err)
+	second, err := store.Add(parcel)
+	require.NoError(t, err)
+	third, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	sent, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(sent, ParcelStatusSent))
+
+	other := parcel
+	other.Address = "другой адрес"
+	otherID, err := store.Add(other)
+	require.NoError(t, err)
+
+	// внешний номер при повторной отправке может отличаться
+	ref := parcel
+	ref.ExternalRef = "order-2"
+	refID, err := store.Add(ref)
+	require.NoError(t, err)
+
+	// адрес, записанный в обход нормализации
+	padded, err := store.Add(parcel)
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE parcel SET address = ? WHERE number = ?", " "+parcel.Address+" ", padded)
+	require.NoError(t, err)
+
+	held, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.Hold(held, "legal"))
+
+	// check
+	removed, err := store.DeduplicateClient(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, 4, removed)
+
+	parcels, err := store.Query(QueryOptions{Client: parcel.Client})
+	require.NoError(t, err)
+	var numbers []int64
+	for _, p := range parcels {
+		numbers = append(numbers, p.Number)
+	}
+	require.ElementsMatch(t, []int64{first, sent, otherID, held}, numbers)
+
+	for _, number := range []int64{second, third, refID, padded} {
+		var original int64
+		err = db.QueryRow("SELECT duplicate_of FROM parcel_duplicate WHERE number = ?", number).Scan(&original)
+		require.NoError(t, err)
+		require.Equal(t, first, original)
+	}
+
+	removed, err = store.DeduplicateClient(parcel.Client)
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+
+	// день считается по UTC, а не по смещению в строке
+	require.Equal(t, "2024-01-01", createdDay("2024-01-02T01:00:00+03:00"))
+}
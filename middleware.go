@@ -0,0 +1,24 @@
+package main
+
+import "time"
+
+// Middleware оборачивает Store сквозной логикой: проверкой прав, аудитом, метриками.
+// Обёртка обычно встраивает next и переопределяет нужные методы.
+type Middleware func(next Store) Store
+
+// Chain оборачивает store в middlewares. Первая обёртка внешняя:
+// Chain(s, a, b) вызывает a, затем b, затем s.
+func Chain(store Store, middlewares ...Middleware) Store {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		store = middlewares[i](store)
+	}
+
+	return store
+}
+
+// CachingMiddleware оборачивает Store в CachingStore с заданными размером и временем жизни записей
+func CachingMiddleware(size int, ttl time.Duration) Middleware {
+	return func(next Store) Store {
+		return NewCachingStore(next, size, ttl)
+	}
+}
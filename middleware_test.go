@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStore записывает вызовы Get под своим именем
+type recordingStore struct {
+	Store
+	name  string
+	calls *[]string
+}
+
+func (s recordingStore) Get(number int64) (Parcel, error) {
+	*s.calls = append(*s.calls, s.name)
+	return s.Store.Get(number)
+}
+
+func recording(name string, calls *[]string) Middleware {
+	return func(next Store) Store {
+		return recordingStore{Store: next, name: name, calls: calls}
+	}
+}
+
+// denyDelete запрещает удаление
+type denyDelete struct {
+	Store
+}
+
+var errDenied = errors.New("denied")
+
+func (s denyDelete) Delete(number int64) error {
+	return errDenied
+}
+
+// TestChain проверяет порядок применения обёрток хранилища
+func TestChain(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var calls []string
+	store := Chain(NewParcelStore(db),
+		recording("outer", &calls),
+		func(next Store) Store { return denyDelete{next} },
+		CachingMiddleware(10, time.Minute),
+		recording("inner", &calls),
+	)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	_, err = store.Get(id)
+	require.NoError(t, err)
+	_, err = store.Get(id)
+	require.NoError(t, err)
+	// второй Get обслужил кэш, до inner он не дошёл
+	require.Equal(t, []string{"outer", "inner", "outer"}, calls)
+
+	require.ErrorIs(t, store.Delete(id), errDenied)
+	_, err = NewParcelStore(db).Get(id)
+	require.NoError(t, err)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "strings"
+
+// TrimParcel нормализатор по умолчанию (StoreConfig.Normalize): обрезает пробелы по краям Address и Note
+func TrimParcel(p Parcel) Parcel {
+	p.Address = strings.TrimSpace(p.Address)
+	p.Note = strings.TrimSpace(p.Note)
+
+	return p
+}
+
+// normalizeAddress приводит адрес к тому виду, в котором он хранится.
+// Нормализатор получает посылку, в которой заполнен только Address.
+func (s ParcelStore) normalizeAddress(address string) string {
+	return s.cfg.Normalize(Parcel{Address: address}).Address
+}
+
+// GetByAddress возвращает посылки с адресом address. Адрес нормализуется так же,
+// как при записи, поэтому, например, пробелы по краям не мешают поиску.
+func (s ParcelStore) GetByAddress(address string) (res []Parcel, err error) {
+	span := s.startSpan("GetByAddress")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels("WHERE address = ? AND deleted_at IS NULL AND status <> ? ORDER BY number",
+		s.normalizeAddress(address), ParcelStatusDraft)
+}
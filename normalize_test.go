@@ -0,0 +1,59 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalize проверяет нормализацию полей посылки перед записью и при поиске по адресу
+func TestNormalize(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	address := fmt.Sprintf("Тверь, ул. Советская, %d", randRange.Intn(10_000_000))
+	parcel := getTestParcel()
+	parcel.Address = "  " + address + "\n"
+	parcel.Note = " позвонить "
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, address, stored.Address)
+	require.Equal(t, "позвонить", stored.Note)
+
+	found, err := store.GetByAddress(" " + address)
+	require.NoError(t, err)
+	require.Equal(t, []Parcel{stored}, found)
+
+	// свой нормализатор: схлопывает пробелы и приводит индекс к верхнему регистру
+	store = NewParcelStoreWithConfig(db, StoreConfig{Normalize: func(p Parcel) Parcel {
+		p.Address = strings.Join(strings.Fields(p.Address), " ")
+		p.PostalCode = strings.ToUpper(p.PostalCode)
+		return p
+	}})
+	parcel = getTestParcel()
+	parcel.PostalCode = "sw1a 1aa"
+	id, err = store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetAddress(id, "Лондон,   "+address))
+
+	stored, err = store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, "SW1A 1AA", stored.PostalCode)
+	require.Equal(t, "Лондон, "+address, stored.Address)
+
+	found, err = store.GetByAddress("Лондон, \t" + address)
+	require.NoError(t, err)
+	require.Equal(t, []Parcel{stored}, found)
+}
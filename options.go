@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// Option настройка хранилища для NewParcelStore, меняет соответствующее поле StoreConfig.
+// Для настроек, у которых нет своей Option, есть NewParcelStoreWithConfig.
+type Option func(c *StoreConfig)
+
+// WithDialect задаёт диалект SQL, см. StoreConfig.Dialect
+func WithDialect(d Dialect) Option {
+	return func(c *StoreConfig) { c.Dialect = d }
+}
+
+// WithLogger задаёт логгер запросов, см. StoreConfig.SQLLogger
+func WithLogger(l *slog.Logger) Option {
+	return func(c *StoreConfig) { c.SQLLogger = l }
+}
+
+// WithClock задаёт источник текущего времени, см. StoreConfig.Now
+func WithClock(now func() time.Time) Option {
+	return func(c *StoreConfig) { c.Now = now }
+}
+
+// WithRetry задаёт число повторов транзакции и паузу перед первым повтором,
+// см. StoreConfig.MaxRetries и StoreConfig.RetryDelay
+func WithRetry(maxRetries int, delay time.Duration) Option {
+	return func(c *StoreConfig) {
+		c.MaxRetries = maxRetries
+		c.RetryDelay = delay
+	}
+}
+
+// WithReadReplica задаёт реплику для чтения, см. StoreConfig.ReadReplica
+func WithReadReplica(db *sql.DB) Option {
+	return func(c *StoreConfig) { c.ReadReplica = db }
+}
+
+// WithAudit включает журнал аудита, см. StoreConfig.Audit
+func WithAudit() Option {
+	return func(c *StoreConfig) { c.Audit = true }
+}
+
+// WithOutbox включает запись событий в outbox, см. StoreConfig.Outbox
+func WithOutbox() Option {
+	return func(c *StoreConfig) { c.Outbox = true }
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestOptions проверяет, что функциональные опции попадают в настройки хранилища
+func TestOptions(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// check
+	store := NewParcelStore(db,
+		WithDialect(DialectPostgres),
+		WithLogger(logger),
+		WithClock(func() time.Time { return now }),
+		WithRetry(-1, time.Second),
+		WithReadReplica(db),
+		WithAudit(),
+		WithOutbox(),
+	)
+	require.Equal(t, DialectPostgres, store.cfg.Dialect)
+	require.Same(t, logger, store.cfg.SQLLogger)
+	require.Equal(t, now, store.cfg.Now())
+	require.Equal(t, -1, store.cfg.MaxRetries)
+	require.Equal(t, time.Second, store.cfg.RetryDelay)
+	require.Same(t, db, store.cfg.ReadReplica)
+	require.True(t, store.cfg.Audit)
+	require.True(t, store.cfg.Outbox)
+
+	// без опций — настройки по умолчанию
+	store = NewParcelStore(db)
+	require.Equal(t, DialectSQLite, store.cfg.Dialect)
+	require.Equal(t, 3, store.cfg.MaxRetries)
+	require.Nil(t, store.cfg.ReadReplica)
+}
+
+// TestConfig проверяет, что Config отдаёт копию действующих настроек
+func TestConfig(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStoreWithConfig(db, StoreConfig{Audit: true, Carriers: []string{"cdek"}})
+
+	// check
+	cfg := store.Config()
+	require.Equal(t, DialectSQLite, cfg.Dialect)
+	require.True(t, cfg.Audit)
+	require.False(t, cfg.Outbox)
+	require.Equal(t, DefaultMaxBatchSize, cfg.MaxBatchSize)
+	require.Equal(t, []string{"cdek"}, cfg.Carriers)
+
+	cfg.Audit = false
+	cfg.Carriers[0] = "dhl"
+	require.True(t, store.Config().Audit)
+	require.Equal(t, []string{"cdek"}, store.Config().Carriers)
+}
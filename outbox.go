@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OutboxEvent событие об изменении посылки из таблицы parcel_outbox
+type OutboxEvent struct {
+	ID        int64
+	Type      string // операция, как в AuditEntry.Operation, например "set status"
+	Payload   string // JSON с номером посылки и её состоянием до и после изменения, см. outboxPayload
+	CreatedAt string // RFC3339, UTC
+}
+
+// outboxPayload содержимое OutboxEvent.Payload.
+// Before нет, если посылку добавили, After — если её удалили.
+type outboxPayload struct {
+	Number int64           `json:"number"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// writeEvent добавляет неопубликованное событие op о посылке number в outbox
+func (s ParcelStore) writeEvent(op string, number int64, before, after sql.NullString) error {
+	payload := outboxPayload{Number: number}
+	if before.Valid {
+		payload.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		payload.After = json.RawMessage(after.String)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("INSERT INTO parcel_outbox (type, payload, created_at, number) VALUES (?, ?, ?, ?)",
+		op, string(data), formatTime(s.cfg.Now()), number)
+	return err
+}
+
+// FetchUnpublished возвращает до limit неопубликованных событий в порядке записи.
+// Читает с основной БД, а не с реплики, чтобы не пропустить только что записанные события.
+func (s ParcelStore) FetchUnpublished(limit int) (res []OutboxEvent, err error) {
+	span := s.startSpan("FetchUnpublished", attribute.Int("outbox.limit", limit))
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.db.Query("SELECT id, type, payload, created_at FROM parcel_outbox WHERE published = 0 ORDER BY id LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
+
+// MarkPublished отмечает события ids опубликованными, незнакомые id пропускаются.
+// Повторная отметка безопасна, поэтому событие, опубликованное дважды из-за сбоя
+// между публикацией и MarkPublished, не ломает outbox (доставка «хотя бы раз»).
+func (s ParcelStore) MarkPublished(ids []int64) (err error) {
+	span := s.startSpan("MarkPublished", attribute.Int("outbox.count", len(ids)))
+	defer func() { endSpan(span, err) }()
+
+	return s.forEachBatch(len(ids), func(start, end int) error {
+		if start == end {
+			return nil
+		}
+
+		args := make([]any, 0, end-start)
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+		}
+
+		_, err := s.db.Exec("UPDATE parcel_outbox SET published = 1 WHERE id IN ("+placeholders(len(args))+")", args...)
+		return err
+	})
+}
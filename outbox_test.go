@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutbox проверяет запись событий в outbox и их отметку опубликованными
+func TestOutbox(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db, WithOutbox())
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	// в outbox могут быть и чужие события, отбираем события этой посылки
+	events := func() []OutboxEvent {
+		all, err := store.FetchUnpublished(10_000)
+		require.NoError(t, err)
+
+		var res []OutboxEvent
+		for _, e := range all {
+			var payload outboxPayload
+			require.NoError(t, json.Unmarshal([]byte(e.Payload), &payload))
+			if payload.Number == id {
+				res = append(res, e)
+			}
+		}
+		return res
+	}
+
+	got := events()
+	require.Len(t, got, 2)
+	require.Equal(t, "add", got[0].Type)
+	require.Equal(t, "set status", got[1].Type)
+
+	var payload outboxPayload
+	require.NoError(t, json.Unmarshal([]byte(got[1].Payload), &payload))
+	var before, after Parcel
+	require.NoError(t, json.Unmarshal(payload.Before, &before))
+	require.NoError(t, json.Unmarshal(payload.After, &after))
+	require.Equal(t, ParcelStatusRegistered, before.Status)
+	require.Equal(t, ParcelStatusSent, after.Status)
+
+	// без Audit журнал аудита не пишется
+	trail, err := store.GetAuditTrail(id)
+	require.NoError(t, err)
+	require.Empty(t, trail)
+
+	require.NoError(t, store.MarkPublished([]int64{got[0].ID, got[1].ID}))
+	require.Empty(t, events())
+	require.NoError(t, store.MarkPublished(nil))
+}
+
+// TestOutboxBulkChanges проверяет события массовых изменений: по событию на каждую посылку
+func TestOutboxBulkChanges(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db, WithOutbox())
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000) + 1
+	parcel.ScheduledAt = formatTime(time.Now().Add(-time.Hour))
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	n, err := store.TransferParcels(parcel.Client, parcel.Client+1)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	_, err = store.AdvanceDuePickups(time.Now())
+	require.NoError(t, err)
+
+	all, err := store.FetchUnpublished(10_000)
+	require.NoError(t, err)
+	var types []string
+	for _, e := range all {
+		var payload outboxPayload
+		require.NoError(t, json.Unmarshal([]byte(e.Payload), &payload))
+		if payload.Number == id {
+			types = append(types, e.Type)
+		}
+	}
+	require.Equal(t, []string{"add", "transfer", "advance pickup"}, types)
+}
@@ -1,60 +1,656 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrParcelNotFound возвращается, если искомой посылки нет в таблице
+	ErrParcelNotFound = errors.New("parcel not found")
+	// ErrParcelNotDeletable удалить можно только посылку в статусе registered
+	ErrParcelNotDeletable = errors.New("parcel cannot be deleted in its status")
 )
 
+// parcelColumns колонки таблицы parcel в том порядке, в котором их читает scanParcel
+const parcelColumns = "number, client, status, address, created_at, external_ref, city, note, scheduled_at, deleted_at, public_id, signed_by, client_code, claimed_by, carrier, street, postal_code, country, priority, on_hold, hold_reason, recipient_phone, recipient_name"
+
+// Store операции с посылками, которые использует ParcelService.
+// Реализуется ParcelStore и обёртками над ним, например CachingStore.
+type Store interface {
+	Add(p Parcel) (int64, error)
+	Get(number int64) (Parcel, error)
+	GetByClient(client int64) ([]Parcel, error)
+	SetStatus(number int64, status string) error
+	SetAddress(number int64, address string) error
+	Delete(number int64) error
+}
+
+var _ Store = ParcelStore{}
+
 type ParcelStore struct {
-	db *sql.DB
+	db   querier // запросы идут через него: вне транзакции в conn, внутри WithTx — в tx
+	read querier // запросы на чтение: вне транзакции в StoreConfig.ReadReplica, если она задана, иначе как db
+	conn *sql.DB
+	tx   *sql.Tx // не nil внутри WithTx
+	cfg  StoreConfig
+	life *lifecycle // общий для копий хранилища, см. Shutdown
+
+	owned bool // conn открыт самим хранилищем в NewParcelStoreFromDSN, см. Close
+
+	ctx      context.Context // родитель спанов, см. WithContext; nil — спаны корневые
+	actor    string          // актор для журнала аудита, см. WithContext
+	auditing bool            // изменение уже пишется в журнал аудита внешним вызовом
 }
 
-func NewParcelStore(db *sql.DB) ParcelStore {
-	return ParcelStore{db: db}
+// NewParcelStore создаёт хранилище с настройками opts, без них — с настройками по умолчанию
+func NewParcelStore(db *sql.DB, opts ...Option) ParcelStore {
+	var cfg StoreConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return NewParcelStoreWithConfig(db, cfg)
 }
 
-func (s ParcelStore) Add(p Parcel) (int, error) {
+func (s ParcelStore) Add(p Parcel) (id int64, err error) {
+	span := s.startSpan("Add", attribute.Int64(attrParcelClient, p.Client))
+	defer func() {
+		span.SetAttributes(attribute.Int64(attrParcelNumber, id))
+		endSpan(span, err)
+	}()
+
+	if s.tracksChanges() {
+		return s.audited("add", 0, func(tx ParcelStore) (int64, error) { return tx.Add(p) })
+	}
+
+	p, err = s.prepare(p)
+	if err != nil {
+		return 0, err
+	}
+
+	if p.City == "" {
+		p.City = cityFromAddress(p.Address)
+	}
+
+	if p.PublicID == "" && s.cfg.IDGenerator != nil {
+		p.PublicID = s.cfg.IDGenerator()
+	}
+
+	// адрес записывайте как p.addressValue(), чтобы незаданный адрес сохранился как NULL
 	// реализуйте добавление строки в таблицу parcel, используйте данные из переменной p
 
 	// верните идентификатор последней добавленной записи
 	return 0, nil
 }
 
-func (s ParcelStore) Get(number int) (Parcel, error) {
+func (s ParcelStore) Get(number int64) (p Parcel, err error) {
+	span := s.startSpan("Get", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
 	// реализуйте чтение строки по заданному number
 	// здесь из таблицы должна вернуться только одна строка
+	// читайте через s.read, чтобы при заданной StoreConfig.ReadReplica запрос шёл в реплику
+	// мягко удалённая посылка (deleted_at не NULL) считается отсутствующей: ErrParcelNotFound
 
 	// заполните объект Parcel данными из таблицы
-	p := Parcel{}
+	p = Parcel{}
 
 	return p, nil
 }
 
-func (s ParcelStore) GetByClient(client int) ([]Parcel, error) {
+func (s ParcelStore) GetByClient(client int64) (res []Parcel, err error) {
+	span := s.startSpan("GetByClient", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
 	// реализуйте чтение строк из таблицы parcel по заданному client
 	// здесь из таблицы может вернуться несколько строк
+	// читайте через s.read, чтобы при заданной StoreConfig.ReadReplica запрос шёл в реплику
+	// черновики (ParcelStatusDraft) и мягко удалённые посылки в выборку не попадают
 
 	// заполните срез Parcel данными из таблицы
-	var res []Parcel
 
 	return res, nil
 }
 
-func (s ParcelStore) SetStatus(number int, status string) error {
+// GetByClientSafe как GetByClient, но строки с повреждёнными данными, которые не удалось прочитать,
+// не прерывают выборку: их ошибки возвращаются в rowErrs вместе с остальными посылками.
+// err — ошибка самого запроса, при ней посылок нет.
+func (s ParcelStore) GetByClientSafe(client int64) (res []Parcel, rowErrs []error, err error) {
+	span := s.startSpan("GetByClientSafe", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcelsSafe("WHERE client = ? AND deleted_at IS NULL AND status <> ? ORDER BY number", client, ParcelStatusDraft)
+}
+
+func (s ParcelStore) SetStatus(number int64, status string) (err error) {
+	span := s.startSpan("SetStatus", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("set status", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.SetStatus(number, status)
+		})
+		return err
+	}
+
+	if status == ParcelStatusDelivered && s.cfg.RequireSignature {
+		return ErrSignatureRequired
+	}
+
+	if err := s.checkNotOnHold(number); err != nil {
+		return err
+	}
+
+	if err := s.fillDefaultAddress(number, status); err != nil {
+		return err
+	}
+
 	// реализуйте обновление статуса в таблице parcel
+	// добавьте в WHERE on_hold = 0, чтобы Hold, выполненный после проверки выше, не пропустил обновление
 
 	return nil
 }
 
-func (s ParcelStore) SetAddress(number int, address string) error {
+func (s ParcelStore) SetAddress(number int64, address string) (err error) {
+	span := s.startSpan("SetAddress", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("set address", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.SetAddress(number, address)
+		})
+		return err
+	}
+
+	address = s.normalizeAddress(address)
+
+	address, err = limitLength(address, s.cfg.MaxAddressLen, s.cfg.LengthPolicy, ErrAddressTooLong)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkNotOnHold(number); err != nil {
+		return err
+	}
+
 	// реализуйте обновление адреса в таблице parcel
-	// менять адрес можно только если значение статуса registered
+	// менять адрес можно только если значение статуса registered или draft
+	// добавьте в WHERE on_hold = 0, чтобы Hold, выполненный после проверки выше, не пропустил обновление
 
 	return nil
 }
 
-func (s ParcelStore) Delete(number int) error {
+func (s ParcelStore) Delete(number int64) (err error) {
+	span := s.startSpan("Delete", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("delete", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.Delete(number)
+		})
+		return err
+	}
+
+	if err := s.checkNotOnHold(number); err != nil {
+		return err
+	}
+
 	// реализуйте удаление строки из таблицы parcel
 	// удалять строку можно только если значение статуса registered
+	// добавьте в WHERE on_hold = 0, чтобы задержанная Hold посылка не удалилась
 
 	return nil
 }
+
+// DeleteReturning удаляет посылку и возвращает её в том виде, в каком она была удалена.
+// Чтение и удаление идут в одной транзакции, так что посылка не может измениться между ними.
+// Если посылки нет, возвращает ErrParcelNotFound, если её статус не registered — ErrParcelNotDeletable.
+func (s ParcelStore) DeleteReturning(number int64) (deleted Parcel, err error) {
+	span := s.startSpan("DeleteReturning", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		deleted, err = tx.GetForUpdate(number)
+		if err != nil {
+			return err
+		}
+		if deleted.Status != ParcelStatusRegistered {
+			return fmt.Errorf("%w: %q", ErrParcelNotDeletable, deleted.Status)
+		}
+		if deleted.OnHold {
+			return ErrParcelOnHold
+		}
+
+		return tx.Delete(number)
+	})
+	if err != nil {
+		return Parcel{}, err
+	}
+
+	return deleted, nil
+}
+
+// fillDefaultAddress заполняет незаданный адрес посылки (или заглушку) значением StoreConfig.DefaultAddress
+// для статуса status, в который она переходит. Без настройки для статуса ничего не делает.
+func (s ParcelStore) fillDefaultAddress(number int64, status string) error {
+	hook := s.cfg.DefaultAddress[status]
+	if hook == nil {
+		return nil
+	}
+
+	if s.tracksChanges() {
+		_, err := s.audited("default address", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.fillDefaultAddress(number, status)
+		})
+		return err
+	}
+
+	// посылку читаем с основной базы: реплика может ещё не знать о недавнем изменении
+	p, err := s.primary().queryParcel("number = ? AND deleted_at IS NULL", number)
+	if err != nil {
+		return err
+	}
+	// намеренно пустой адрес не заменяется, заглушка — заменяется
+	placeholder := s.cfg.AddressPlaceholder != "" && p.Address == s.cfg.AddressPlaceholder
+	if p.AddressSet && !placeholder {
+		return nil
+	}
+
+	address := hook(p)
+	if address == "" {
+		return nil
+	}
+	address = s.normalizeAddress(address)
+
+	address, err = limitLength(address, s.cfg.MaxAddressLen, s.cfg.LengthPolicy, ErrAddressTooLong)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE parcel SET address = ? WHERE number = ?", address, number)
+	return err
+}
+
+// prepare нормализует и проверяет посылку перед Add, не обращаясь к БД
+func (s ParcelStore) prepare(p Parcel) (Parcel, error) {
+	p = s.cfg.Normalize(p)
+	p.RecipientPhone = normalizePhone(p.RecipientPhone)
+	p.RecipientName = strings.TrimSpace(p.RecipientName)
+
+	// адрес, заданный по частям (хотя бы улицей), сохраняется и целиком
+	if p.Address == "" && !p.AddressSet && p.Street != "" {
+		p.Address = p.FormatAddress()
+	}
+
+	// черновик можно сохранить незаполненным, остальные посылки проверяются полностью
+	if p.Status != ParcelStatusDraft {
+		if err := p.Validate(); err != nil {
+			return Parcel{}, err
+		}
+	}
+
+	var err error
+	if p.CreatedAt, err = s.checkCreatedAt(p.CreatedAt); err != nil {
+		return Parcel{}, err
+	}
+	if p.ScheduledAt, err = normalizeScheduledAt(p.ScheduledAt); err != nil {
+		return Parcel{}, err
+	}
+
+	p.Address, err = limitLength(p.Address, s.cfg.MaxAddressLen, s.cfg.LengthPolicy, ErrAddressTooLong)
+	if err != nil {
+		return Parcel{}, err
+	}
+	p.Note, err = limitLength(p.Note, s.cfg.MaxNoteLen, s.cfg.LengthPolicy, ErrNoteTooLong)
+	if err != nil {
+		return Parcel{}, err
+	}
+
+	return p, nil
+}
+
+// AddReturning добавляет посылку и возвращает её в том виде, в котором она сохранена в БД,
+// с номером и заполненными при добавлении полями (например, City).
+// Вставка идёт через Add, чтобы проверки и нормализация были общими для всех путей добавления,
+// а чтение — в той же транзакции, так что между ними посылку никто не изменит.
+func (s ParcelStore) AddReturning(p Parcel) (stored Parcel, err error) {
+	span := s.startSpan("AddReturning", attribute.Int64(attrParcelClient, p.Client))
+	defer func() { endSpan(span, err) }()
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		id, err := tx.Add(p)
+		if err != nil {
+			return err
+		}
+
+		stored, err = tx.queryParcel("number = ? AND deleted_at IS NULL", id)
+		return err
+	})
+	if err != nil {
+		return Parcel{}, err
+	}
+
+	return stored, nil
+}
+
+// FilterOwned делит номера посылок на принадлежащие клиенту и все остальные.
+// Принадлежность проверяется одним запросом (по запросу на пачку, если номеров больше
+// StoreConfig.MaxBatchSize и задан ChunkBatches), порядок номеров сохраняется.
+func (s ParcelStore) FilterOwned(client int64, numbers []int64) (owned []int64, notOwned []int64, err error) {
+	span := s.startSpan("FilterOwned", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	if len(numbers) == 0 {
+		return nil, nil, nil
+	}
+
+	found := make(map[int64]bool, len(numbers))
+	err = s.forEachBatch(len(numbers), func(start, end int) error {
+		return s.findOwned(client, numbers[start:end], found)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, number := range numbers {
+		if found[number] {
+			owned = append(owned, number)
+		} else {
+			notOwned = append(notOwned, number)
+		}
+	}
+
+	return owned, notOwned, nil
+}
+
+// placeholders возвращает n параметров запроса через запятую: "?, ?, ?"
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// findOwned отмечает в found номера из numbers, принадлежащие клиенту client
+func (s ParcelStore) findOwned(client int64, numbers []int64, found map[int64]bool) error {
+	args := make([]any, 0, len(numbers)+1)
+	args = append(args, client)
+	for _, number := range numbers {
+		args = append(args, number)
+	}
+
+	rows, err := s.read.Query("SELECT number FROM parcel WHERE client = ? AND deleted_at IS NULL AND number IN ("+placeholders(len(numbers))+")", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var number int64
+		if err := rows.Scan(&number); err != nil {
+			return err
+		}
+		found[number] = true
+	}
+
+	return rows.Err()
+}
+
+// GetByExternalRef возвращает посылку по внешнему номеру заказа
+func (s ParcelStore) GetByExternalRef(ref string) (p Parcel, err error) {
+	span := s.startSpan("GetByExternalRef")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcel("external_ref = ? AND deleted_at IS NULL ORDER BY number LIMIT 1", ref)
+}
+
+// AddWithClientCode добавляет посылку вместе с кодом клиента во внешней системе
+func (s ParcelStore) AddWithClientCode(p Parcel, code string) (id int64, err error) {
+	span := s.startSpan("AddWithClientCode", attribute.Int64(attrParcelClient, p.Client))
+	defer func() { endSpan(span, err) }()
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return 0, &MissingFieldsError{Fields: []string{"ClientCode"}}
+	}
+
+	p.ClientCode = code
+	return s.Add(p)
+}
+
+// GetByClientCode возвращает посылки клиента по его коду во внешней системе (точное совпадение)
+func (s ParcelStore) GetByClientCode(code string) (res []Parcel, err error) {
+	span := s.startSpan("GetByClientCode")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels("WHERE client_code = ? AND deleted_at IS NULL AND status <> ? ORDER BY number", code, ParcelStatusDraft)
+}
+
+// SearchByExternalRef возвращает посылки, внешний номер которых начинается с prefix
+func (s ParcelStore) SearchByExternalRef(prefix string) (res []Parcel, err error) {
+	span := s.startSpan("SearchByExternalRef")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels(`WHERE external_ref LIKE ? ESCAPE '\' AND deleted_at IS NULL ORDER BY external_ref, number`, escapeLike(prefix)+"%")
+}
+
+// GetCountsByCity возвращает количество посылок по городам.
+// Посылки без города и черновики в результат не попадают.
+func (s ParcelStore) GetCountsByCity() (counts map[string]int, err error) {
+	span := s.startSpan("GetCountsByCity")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query("SELECT city, COUNT(*) FROM parcel WHERE city IS NOT NULL AND city <> '' AND status <> ? AND deleted_at IS NULL GROUP BY city",
+		ParcelStatusDraft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts = make(map[string]int)
+	for rows.Next() {
+		var city string
+		var n int
+		if err := rows.Scan(&city, &n); err != nil {
+			return nil, err
+		}
+		counts[city] = n
+	}
+
+	return counts, rows.Err()
+}
+
+// GetOldestUndeliveredPerClient возвращает для каждого клиента самую раннюю (по CreatedAt)
+// посылку, которая ещё в пути. Черновики, а также доставленные, утерянные и возвращённые посылки
+// не учитываются, клиентов без посылок в пути в результате нет.
+func (s ParcelStore) GetOldestUndeliveredPerClient() (res map[int64]Parcel, err error) {
+	span := s.startSpan("GetOldestUndeliveredPerClient")
+	defer func() { endSpan(span, err) }()
+
+	// при одинаковом created_at запрос вернёт несколько посылок клиента, берём первую по номеру
+	parcels, err := s.queryParcels(`WHERE status IN (?, ?, ?) AND deleted_at IS NULL
+		AND created_at = (SELECT MIN(o.created_at) FROM parcel o
+			WHERE o.client = parcel.client AND o.status IN (?, ?, ?) AND o.deleted_at IS NULL)
+		ORDER BY client, number`,
+		ParcelStatusRegistered, ParcelStatusProcessing, ParcelStatusSent,
+		ParcelStatusRegistered, ParcelStatusProcessing, ParcelStatusSent)
+	if err != nil {
+		return nil, err
+	}
+
+	res = make(map[int64]Parcel)
+	for _, p := range parcels {
+		if _, ok := res[p.Client]; !ok {
+			res[p.Client] = p
+		}
+	}
+
+	return res, nil
+}
+
+// cityFromAddress возвращает город — часть адреса до первой запятой,
+// например "Псков" для "Псков, д. Пушкина, ул. Колотушкина, д. 5"
+func cityFromAddress(address string) string {
+	city, _, _ := strings.Cut(address, ",")
+	return strings.TrimSpace(city)
+}
+
+// FindDuplicates возвращает группы посылок с одинаковыми клиентом и адресом, не считая черновиков.
+// Внутри группы посылки упорядочены по времени создания.
+func (s ParcelStore) FindDuplicates() (clusters [][]Parcel, err error) {
+	span := s.startSpan("FindDuplicates")
+	defer func() { endSpan(span, err) }()
+
+	parcels, err := s.queryParcels(`WHERE status <> ? AND deleted_at IS NULL AND (client, address) IN (
+			SELECT client, address FROM parcel WHERE status <> ? AND deleted_at IS NULL
+			GROUP BY client, address HAVING COUNT(*) > 1
+		) ORDER BY client, address, created_at, number`, ParcelStatusDraft, ParcelStatusDraft)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range parcels {
+		if i == 0 || p.Client != parcels[i-1].Client || p.Address != parcels[i-1].Address {
+			clusters = append(clusters, nil)
+		}
+		clusters[len(clusters)-1] = append(clusters[len(clusters)-1], p)
+	}
+
+	return clusters, nil
+}
+
+// GetIncomplete возвращает зарегистрированные посылки, адрес которых ещё не заполнен:
+// не задан (NULL) или равен StoreConfig.AddressPlaceholder. Намеренно пустой адрес заполненным считается.
+func (s ParcelStore) GetIncomplete() (res []Parcel, err error) {
+	span := s.startSpan("GetIncomplete")
+	defer func() { endSpan(span, err) }()
+
+	if s.cfg.AddressPlaceholder == "" {
+		return s.queryParcels("WHERE status = ? AND address IS NULL AND deleted_at IS NULL ORDER BY created_at, number", ParcelStatusRegistered)
+	}
+
+	return s.queryParcels("WHERE status = ? AND (address IS NULL OR address = ?) AND deleted_at IS NULL ORDER BY created_at, number",
+		ParcelStatusRegistered, s.cfg.AddressPlaceholder)
+}
+
+// ListFutureDated возвращает посылки, у которых CreatedAt опережает текущее время
+// больше чем на StoreConfig.MaxClockSkew, — кандидатов на исправление данных
+func (s ParcelStore) ListFutureDated() (res []Parcel, err error) {
+	span := s.startSpan("ListFutureDated")
+	defer func() { endSpan(span, err) }()
+
+	cond, limit := s.createdAtCond(">", s.cfg.Now().Add(s.cfg.MaxClockSkew))
+
+	return s.queryParcels("WHERE "+cond+" AND deleted_at IS NULL ORDER BY created_at, number", limit)
+}
+
+// rowScanner общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// nullString значение необязательной колонки: пустая строка хранится как NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// addressValue значение колонки address: NULL, если адрес пустой и не задан
+func (p Parcel) addressValue() sql.NullString {
+	return sql.NullString{String: p.Address, Valid: p.Address != "" || p.AddressSet}
+}
+
+// scanParcel читает посылку из строки, выбранной с колонками parcelColumns
+func scanParcel(row rowScanner) (Parcel, error) {
+	var p Parcel
+	var externalRef, city, note, scheduledAt, deletedAt, publicID, signedBy, clientCode, claimedBy, carrier sql.NullString
+	var address, street, postalCode, country, holdReason, recipientPhone, recipientName sql.NullString
+
+	err := row.Scan(&p.Number, &p.Client, &p.Status, &address, &p.CreatedAt,
+		&externalRef, &city, &note, &scheduledAt, &deletedAt, &publicID, &signedBy, &clientCode, &claimedBy, &carrier,
+		&street, &postalCode, &country, &p.Priority, &p.OnHold, &holdReason, &recipientPhone, &recipientName)
+	if err != nil {
+		return Parcel{}, err
+	}
+	p.Address = address.String
+	p.AddressSet = address.Valid
+	p.ExternalRef = externalRef.String
+	p.City = city.String
+	p.Note = note.String
+	p.ScheduledAt = scheduledAt.String
+	p.DeletedAt = deletedAt.String
+	p.PublicID = publicID.String
+	p.SignedBy = signedBy.String
+	p.ClientCode = clientCode.String
+	p.ClaimedBy = claimedBy.String
+	p.Carrier = carrier.String
+	p.Street = street.String
+	p.PostalCode = postalCode.String
+	p.Country = country.String
+	p.HoldReason = holdReason.String
+	p.RecipientPhone = recipientPhone.String
+	p.RecipientName = recipientName.String
+
+	return p, nil
+}
+
+// queryParcel читает одну посылку, подходящую под условие where.
+// Если такой нет, возвращает ErrParcelNotFound.
+func (s ParcelStore) queryParcel(where string, args ...any) (Parcel, error) {
+	row := s.read.QueryRow("SELECT "+parcelColumns+" FROM parcel WHERE "+where, args...)
+
+	p, err := scanParcel(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Parcel{}, ErrParcelNotFound
+	}
+
+	return p, err
+}
+
+// queryParcels читает посылки, tail — часть запроса после FROM (WHERE, ORDER BY и т.д.)
+func (s ParcelStore) queryParcels(tail string, args ...any) ([]Parcel, error) {
+	rows, err := s.read.Query("SELECT "+parcelColumns+" FROM parcel "+tail, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []Parcel
+	for rows.Next() {
+		p, err := scanParcel(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+
+	return res, rows.Err()
+}
+
+// queryParcelsSafe как queryParcels, но строку, которую не удалось прочитать, пропускает
+// и возвращает её ошибку в rowErrs, а не прерывает выборку
+func (s ParcelStore) queryParcelsSafe(tail string, args ...any) (res []Parcel, rowErrs []error, err error) {
+	rows, err := s.read.Query("SELECT "+parcelColumns+" FROM parcel "+tail, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for i := 0; rows.Next(); i++ {
+		p, err := scanParcel(rows)
+		if err != nil {
+			rowErrs = append(rowErrs, fmt.Errorf("parcel row %d: %w", i, err))
+			continue
+		}
+		res = append(res, p)
+	}
+
+	return res, rowErrs, rows.Err()
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы искать по строке буквально (ESCAPE '\')
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
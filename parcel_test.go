@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"math/rand"
 	"testing"
 	"time"
@@ -21,10 +22,12 @@ var (
 // getTestParcel возвращает тестовую посылку
 func getTestParcel() Parcel {
 	return Parcel{
-		Client:    1000,
-		Status:    ParcelStatusRegistered,
-		Address:   "test",
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Client:     1000,
+		Status:     ParcelStatusRegistered,
+		Address:    "test",
+		AddressSet: true,
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		City:       "test",
 	}
 }
 
@@ -88,10 +91,10 @@ func TestGetByClient(t *testing.T) {
 		getTestParcel(),
 		getTestParcel(),
 	}
-	parcelMap := map[int]Parcel{}
+	parcelMap := map[int64]Parcel{}
 
 	// задаём всем посылкам один и тот же идентификатор клиента
-	client := randRange.Intn(10_000_000)
+	client := randRange.Int63n(10_000_000)
 	parcels[0].Client = client
 	parcels[1].Client = client
 	parcels[2].Client = client
@@ -119,3 +122,560 @@ func TestGetByClient(t *testing.T) {
 		// убедитесь, что значения полей полученных посылок заполнены верно
 	}
 }
+
+// TestFilterOwned проверяет разделение номеров посылок по владельцу
+func TestFilterOwned(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+
+	otherID, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NotEmpty(t, otherID)
+
+	// check
+	owned, notOwned, err := store.FilterOwned(parcel.Client, []int64{otherID, id})
+	require.NoError(t, err)
+	require.Equal(t, []int64{id}, owned)
+	require.Equal(t, []int64{otherID}, notOwned)
+}
+
+// TestGetByExternalRef проверяет поиск посылки по внешнему номеру заказа
+func TestGetByExternalRef(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.ExternalRef = fmt.Sprintf("order_%d", randRange.Intn(10_000_000))
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	parcel.Number = id
+
+	// get
+	stored, err := store.GetByExternalRef(parcel.ExternalRef)
+	require.NoError(t, err)
+	require.Equal(t, parcel, stored)
+
+	_, err = store.GetByExternalRef(parcel.ExternalRef + "_missing")
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	// search
+	// "_" в префиксе должен искаться буквально, а не как любой символ
+	found, err := store.SearchByExternalRef("order_")
+	require.NoError(t, err)
+	require.Contains(t, found, parcel)
+
+	found, err = store.SearchByExternalRef("order%")
+	require.NoError(t, err)
+	require.Empty(t, found)
+}
+
+// TestGetByClientCode проверяет поиск посылок по коду клиента во внешней системе
+func TestGetByClientCode(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	code := fmt.Sprintf("ACME_%d", randRange.Intn(10_000_000))
+
+	// add
+	id, err := store.AddWithClientCode(getTestParcel(), code)
+	require.NoError(t, err)
+
+	_, err = store.AddWithClientCode(getTestParcel(), " ")
+	require.ErrorIs(t, err, ErrIncompleteParcel)
+
+	// check
+	found, err := store.GetByClientCode(code)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, id, found[0].Number)
+	require.Equal(t, code, found[0].ClientCode)
+
+	// совпадение только точное, символы шаблонов LIKE не действуют
+	found, err = store.GetByClientCode("ACME%")
+	require.NoError(t, err)
+	require.Empty(t, found)
+}
+
+// TestSeedRandom проверяет заполнение хранилища случайными посылками
+func TestSeedRandom(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// seed
+	numbers, err := SeedRandom(store, 5)
+	require.NoError(t, err)
+	require.Len(t, numbers, 5)
+
+	// check
+	for _, number := range numbers {
+		p, err := store.Get(number)
+		require.NoError(t, err)
+		require.Equal(t, number, p.Number)
+		require.NotEmpty(t, p.Address)
+	}
+}
+
+// TestGetCountsByCity проверяет подсчёт посылок по городам
+func TestGetCountsByCity(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	city := fmt.Sprintf("Город %d", randRange.Intn(10_000_000))
+
+	// add
+	// город явно не задан, Add должен взять его из адреса
+	for i := 0; i < 2; i++ {
+		parcel := getTestParcel()
+		parcel.City = ""
+		parcel.Address = city + ", ул. Ленина, д. 1"
+		_, err := store.Add(parcel)
+		require.NoError(t, err)
+	}
+
+	// check
+	counts, err := store.GetCountsByCity()
+	require.NoError(t, err)
+	require.Equal(t, 2, counts[city])
+}
+
+// TestFindDuplicates проверяет поиск посылок с одинаковыми клиентом и адресом
+func TestFindDuplicates(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+
+	// add
+	var numbers []int64
+	for i := 0; i < 2; i++ {
+		id, err := store.Add(parcel)
+		require.NoError(t, err)
+		numbers = append(numbers, id)
+	}
+	unique := parcel
+	unique.Address = "другой адрес"
+	_, err = store.Add(unique)
+	require.NoError(t, err)
+
+	// check
+	clusters, err := store.FindDuplicates()
+	require.NoError(t, err)
+
+	var found []Parcel
+	for _, cluster := range clusters {
+		if cluster[0].Client == parcel.Client {
+			found = cluster
+		}
+	}
+	require.Len(t, found, 2)
+	require.Equal(t, numbers, []int64{found[0].Number, found[1].Number})
+}
+
+// TestGetIncomplete проверяет выборку посылок без заполненного адреса
+func TestGetIncomplete(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStoreWithConfig(db, StoreConfig{AddressPlaceholder: "уточняется"})
+
+	// add
+	placeholder := getTestParcel()
+	placeholder.Address = "уточняется"
+	placeholderID, err := store.Add(placeholder)
+	require.NoError(t, err)
+
+	completeID, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	parcels, err := store.GetIncomplete()
+	require.NoError(t, err)
+
+	numbers := map[int64]bool{}
+	for _, p := range parcels {
+		numbers[p.Number] = true
+	}
+	require.True(t, numbers[placeholderID])
+	require.False(t, numbers[completeID])
+}
+
+// TestFinalize проверяет сохранение черновика и его завершение
+func TestFinalize(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	draft := getTestParcel()
+	draft.Client = randRange.Int63n(10_000_000)
+	draft.Status = ParcelStatusDraft
+	draft.Address = ""
+	draft.AddressSet = false
+
+	// незаполненную посылку можно сохранить только черновиком
+	registered := draft
+	registered.Status = ParcelStatusRegistered
+	_, err = store.Add(registered)
+	require.ErrorIs(t, err, ErrIncompleteParcel)
+
+	id, err := store.Add(draft)
+	require.NoError(t, err)
+
+	// черновик не виден в обычных выборках
+	parcels, err := store.Query(QueryOptions{Client: draft.Client})
+	require.NoError(t, err)
+	require.Empty(t, parcels)
+
+	// finalize
+	err = store.Finalize(id)
+	var missing *MissingFieldsError
+	require.ErrorAs(t, err, &missing)
+	require.Equal(t, []string{"Address"}, missing.Fields)
+
+	require.NoError(t, store.SetAddress(id, "Псков, ул. Колотушкина, д. 5"))
+	require.NoError(t, store.Finalize(id))
+
+	// check
+	p, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusRegistered, p.Status)
+	require.ErrorIs(t, store.Finalize(id), ErrParcelNotDraft)
+}
+
+// TestAddReturning проверяет, что AddReturning возвращает сохранённую посылку
+func TestAddReturning(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.City = ""
+	parcel.Address = "Тверь, ул. Садовая, д. 3"
+
+	// add
+	stored, err := store.AddReturning(parcel)
+	require.NoError(t, err)
+	require.NotEmpty(t, stored.Number)
+	require.Equal(t, "Тверь", stored.City)
+
+	// check
+	p, err := store.Get(stored.Number)
+	require.NoError(t, err)
+	require.Equal(t, p, stored)
+}
+
+// TestDefaultAddress проверяет заполнение адреса по умолчанию при смене статуса
+func TestDefaultAddress(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	warehouse := "Казань, ул. Складская, д. 1"
+	store := NewParcelStoreWithConfig(db, StoreConfig{
+		AddressPlaceholder: "уточняется",
+		DefaultAddress: map[string]func(Parcel) string{
+			ParcelStatusSent: func(Parcel) string { return warehouse },
+		},
+	})
+
+	// add
+	withoutAddress := getTestParcel()
+	withoutAddress.Address = "уточняется"
+	withoutID, err := store.Add(withoutAddress)
+	require.NoError(t, err)
+
+	withID, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// set status
+	require.NoError(t, store.SetStatus(withoutID, ParcelStatusSent))
+	require.NoError(t, store.SetStatus(withID, ParcelStatusSent))
+
+	// check
+	p, err := store.Get(withoutID)
+	require.NoError(t, err)
+	require.Equal(t, warehouse, p.Address)
+
+	p, err = store.Get(withID)
+	require.NoError(t, err)
+	require.Equal(t, "test", p.Address)
+}
+
+// TestGetOldestUndeliveredPerClient проверяет выбор самой ранней недоставленной посылки клиента
+func TestGetOldestUndeliveredPerClient(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	client := randRange.Int63n(10_000_000)
+	deliveredOnly := randRange.Int63n(10_000_000)
+	now := time.Now().UTC()
+
+	add := func(client int64, age time.Duration, status string) int64 {
+		parcel := getTestParcel()
+		parcel.Client = client
+		parcel.CreatedAt = now.Add(-age).Format(time.RFC3339)
+		id, err := store.Add(parcel)
+		require.NoError(t, err)
+		if status != ParcelStatusRegistered {
+			require.NoError(t, store.RepairStatus(id, status))
+		}
+		return id
+	}
+
+	// add
+	add(client, 3*time.Hour, ParcelStatusDelivered)
+	oldest := add(client, 2*time.Hour, ParcelStatusSent)
+	add(client, time.Hour, ParcelStatusRegistered)
+	add(deliveredOnly, time.Hour, ParcelStatusDelivered)
+
+	// check
+	res, err := store.GetOldestUndeliveredPerClient()
+	require.NoError(t, err)
+	require.Equal(t, oldest, res[client].Number)
+	require.NotContains(t, res, deliveredOnly)
+}
+
+// TestDeleteReturning проверяет удаление с возвратом удалённой посылки
+func TestDeleteReturning(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+
+	// delete
+	deleted, err := store.DeleteReturning(id)
+	require.NoError(t, err)
+	require.Equal(t, stored, deleted)
+
+	// check
+	_, err = store.Get(id)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	_, err = store.DeleteReturning(id)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	// посылку не в статусе registered удалить нельзя
+	id, err = store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	_, err = store.DeleteReturning(id)
+	require.ErrorIs(t, err, ErrParcelNotDeletable)
+
+	_, err = store.Get(id)
+	require.NoError(t, err)
+}
+
+// TestLargeClientID проверяет, что 64-битный идентификатор клиента сохраняется без потерь
+func TestLargeClientID(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.Client = 1<<40 + randRange.Int63n(10_000_000)
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, parcel.Client, stored.Client)
+
+	parcels, err := store.GetByClient(parcel.Client)
+	require.NoError(t, err)
+	require.Len(t, parcels, 1)
+	require.Equal(t, id, parcels[0].Number)
+}
+
+// TestGetByClientSafe проверяет, что повреждённая строка не мешает получить остальные посылки клиента
+func TestGetByClientSafe(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	parcel := getTestParcel()
+	parcel.Client = randRange.Int63n(10_000_000)
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	corrupt, err := store.Add(parcel)
+	require.NoError(t, err)
+	// приоритет не число: строку нельзя прочитать в Parcel
+	_, err = db.Exec("UPDATE parcel SET priority = 'high' WHERE number = ?", corrupt)
+	require.NoError(t, err)
+	defer db.Exec("DELETE FROM parcel WHERE number = ?", corrupt)
+
+	// check
+	_, err = store.GetByClient(parcel.Client)
+	require.Error(t, err)
+
+	parcels, rowErrs, err := store.GetByClientSafe(parcel.Client)
+	require.NoError(t, err)
+	require.Len(t, parcels, 1)
+	require.Equal(t, id, parcels[0].Number)
+	require.Len(t, rowErrs, 1)
+}
+
+// TestAddressSet проверяет, что незаданный адрес хранится как NULL, а намеренно пустой — как пустая строка
+func TestAddressSet(t *testing.T) {
+	// prepare
+	// посылки без адреса не должны оставаться в общей tracker.db
+	store := newTempStore(t)
+	db := store.conn
+
+	// add
+	draft := getTestParcel()
+	draft.Status = ParcelStatusDraft
+	draft.Address = ""
+	draft.AddressSet = false
+	draftID, err := store.Add(draft)
+	require.NoError(t, err)
+
+	blank := getTestParcel()
+	blank.Address = ""
+	blankID, err := store.Add(blank)
+	require.NoError(t, err)
+
+	unset, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE parcel SET address = NULL WHERE number = ?", unset)
+	require.NoError(t, err)
+
+	// check
+	var isNull bool
+	require.NoError(t, db.QueryRow("SELECT address IS NULL FROM parcel WHERE number = ?", draftID).Scan(&isNull))
+	require.True(t, isNull)
+	stored, err := store.Get(draftID)
+	require.NoError(t, err)
+	require.False(t, stored.AddressSet)
+
+	stored, err = store.Get(blankID)
+	require.NoError(t, err)
+	require.True(t, stored.AddressSet)
+	require.Empty(t, stored.Address)
+
+	parcels, err := store.GetIncomplete()
+	require.NoError(t, err)
+	numbers := map[int64]bool{}
+	for _, p := range parcels {
+		numbers[p.Number] = true
+	}
+	require.True(t, numbers[unset])
+	require.False(t, numbers[blankID])
+}
+
+// TestReserveComplete проверяет резервирование номера и последующее заполнение посылки
+func TestReserveComplete(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	store := NewParcelStoreWithConfig(db, StoreConfig{Now: func() time.Time { return now }})
+	client := randRange.Int63n(10_000_000)
+
+	// add
+	id, err := store.Reserve(client)
+	require.NoError(t, err)
+	abandoned, err := store.Reserve(client)
+	require.NoError(t, err)
+
+	// check
+	reserved, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusDraft, reserved.Status)
+	require.Equal(t, client, reserved.Client)
+	require.False(t, reserved.AddressSet)
+
+	require.ErrorIs(t, store.Complete(id, Parcel{Note: "без адреса"}), ErrIncompleteParcel)
+	require.NoError(t, store.Complete(id, Parcel{Client: client + 1, Address: "Москва, ул. Ленина, 1", Note: "позвонить"}))
+	require.ErrorIs(t, store.Complete(id, Parcel{Address: "другой"}), ErrParcelNotDraft)
+
+	completed, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusRegistered, completed.Status)
+	require.Equal(t, client, completed.Client)
+	require.Equal(t, "Москва, ул. Ленина, 1", completed.Address)
+	require.Equal(t, "позвонить", completed.Note)
+	require.Equal(t, reserved.CreatedAt, completed.CreatedAt)
+
+	now = now.Add(2 * time.Hour)
+	parcels, err := store.GetAbandonedReservations(time.Hour)
+	require.NoError(t, err)
+	numbers := map[int64]bool{}
+	for _, p := range parcels {
+		numbers[p.Number] = true
+	}
+	require.True(t, numbers[abandoned])
+	require.False(t, numbers[id])
+
+	// с EpochTimestamps сравнивается created_unix, результат тот же
+	epoch := NewParcelStoreWithConfig(db, StoreConfig{Now: store.cfg.Now, EpochTimestamps: true})
+	parcels, err = epoch.GetAbandonedReservations(time.Hour)
+	require.NoError(t, err)
+	numbers = map[int64]bool{}
+	for _, p := range parcels {
+		numbers[p.Number] = true
+	}
+	require.True(t, numbers[abandoned])
+	require.False(t, numbers[id])
+}
@@ -0,0 +1,47 @@
+package main
+
+import "strings"
+
+// normalizePhone приводит телефон к виду, в котором он хранится и ищется:
+// оставляет только цифры и «+» в начале, например "+7 (912) 345-67-89" -> "+79123456789"
+func normalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+
+	var b strings.Builder
+	for i, r := range phone {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// GetByRecipientPhone возвращает посылки всех клиентов с телефоном получателя phone, включая черновики, —
+// для расследования ошибочных доставок и мошенничества. Телефон нормализуется так же, как при записи.
+func (s ParcelStore) GetByRecipientPhone(phone string) (res []Parcel, err error) {
+	span := s.startSpan("GetByRecipientPhone")
+	defer func() { endSpan(span, err) }()
+
+	phone = normalizePhone(phone)
+	if phone == "" {
+		return nil, nil
+	}
+
+	return s.queryParcels("WHERE recipient_phone = ? AND deleted_at IS NULL ORDER BY number", phone)
+}
+
+// GetMissingRecipientInfo возвращает посылки, которые ещё в пути или ждут отправки, но без имени
+// или телефона получателя, — список на проверку перед отправкой. Черновики и посылки
+// в конечном статусе (в том числе доставленные) не попадают.
+func (s ParcelStore) GetMissingRecipientInfo() (res []Parcel, err error) {
+	span := s.startSpan("GetMissingRecipientInfo")
+	defer func() { endSpan(span, err) }()
+
+	terminal := terminalStatuses()
+	args := append([]any{ParcelStatusDraft}, terminal...)
+
+	return s.queryParcels(`WHERE status <> ? AND status NOT IN (`+placeholders(len(terminal))+`) AND deleted_at IS NULL
+		AND (recipient_name IS NULL OR recipient_name = '' OR recipient_phone IS NULL OR recipient_phone = '')
+		ORDER BY created_at, number`, args...)
+}
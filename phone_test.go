@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetByRecipientPhone проверяет поиск посылок разных клиентов по телефону получателя
+func TestGetByRecipientPhone(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	digits := fmt.Sprintf("%07d", randRange.Intn(10_000_000))
+
+	// add
+	first := getTestParcel()
+	first.Client = randRange.Int63n(10_000_000)
+	first.RecipientPhone = "+7 (912) " + digits
+	firstID, err := store.Add(first)
+	require.NoError(t, err)
+
+	second := getTestParcel()
+	second.Client = first.Client + 1
+	second.RecipientPhone = "+7912" + digits
+	secondID, err := store.Add(second)
+	require.NoError(t, err)
+
+	// check
+	stored, err := store.Get(firstID)
+	require.NoError(t, err)
+	require.Equal(t, "+7912"+digits, stored.RecipientPhone)
+
+	parcels, err := store.GetByRecipientPhone(" +7-912-" + digits)
+	require.NoError(t, err)
+	require.Len(t, parcels, 2)
+	require.Equal(t, firstID, parcels[0].Number)
+	require.Equal(t, secondID, parcels[1].Number)
+
+	parcels, err = store.GetByRecipientPhone(" ")
+	require.NoError(t, err)
+	require.Empty(t, parcels)
+}
+
+// TestGetMissingRecipientInfo проверяет список посылок без имени или телефона получателя
+func TestGetMissingRecipientInfo(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	complete := getTestParcel()
+	complete.RecipientName = " Петров П. П. "
+	complete.RecipientPhone = "+79123456789"
+	completeID, err := store.Add(complete)
+	require.NoError(t, err)
+
+	noPhone := complete
+	noPhone.RecipientPhone = ""
+	noPhoneID, err := store.Add(noPhone)
+	require.NoError(t, err)
+
+	noName := complete
+	noName.RecipientName = ""
+	noNameID, err := store.Add(noName)
+	require.NoError(t, err)
+
+	delivered := noName
+	deliveredID, err := store.Add(delivered)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(deliveredID, ParcelStatusSent))
+	require.NoError(t, store.SetStatus(deliveredID, ParcelStatusDelivered))
+
+	draft := noName
+	draft.Status = ParcelStatusDraft
+	draftID, err := store.Add(draft)
+	require.NoError(t, err)
+
+	// check
+	stored, err := store.Get(completeID)
+	require.NoError(t, err)
+	require.Equal(t, "Петров П. П.", stored.RecipientName)
+
+	parcels, err := store.GetMissingRecipientInfo()
+	require.NoError(t, err)
+	found := make(map[int64]bool)
+	for _, p := range parcels {
+		found[p.Number] = true
+	}
+	require.True(t, found[noPhoneID])
+	require.True(t, found[noNameID])
+	require.False(t, found[completeID])
+	require.False(t, found[deliveredID])
+	require.False(t, found[draftID])
+}
@@ -0,0 +1,11 @@
+package main
+
+import "database/sql"
+
+// PoolStats возвращает статистику пула соединений, в котором работает хранилище:
+// открытые и простаивающие соединения, число и общее время ожиданий свободного соединения.
+// Нужна, чтобы подобрать SetMaxOpenConns, не раздавая доступ к самому *sql.DB.
+// Внутри транзакции возвращается статистика того же пула.
+func (s ParcelStore) PoolStats() sql.DBStats {
+	return s.conn.Stats()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPoolStats проверяет, что статистика пула видна и внутри транзакции
+func TestPoolStats(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(3)
+	store := NewParcelStore(db)
+
+	// check
+	require.Equal(t, 3, store.PoolStats().MaxOpenConnections)
+
+	err = store.WithTx(func(tx ParcelStore) error {
+		stats := tx.PoolStats()
+		require.Equal(t, 1, stats.InUse)
+		require.Equal(t, 3, stats.MaxOpenConnections)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, store.PoolStats().InUse)
+}
@@ -0,0 +1,12 @@
+package main
+
+// GetNextByPriority возвращает самую срочную посылку в статусе status: с наибольшим Priority,
+// а при равном — созданную раньше остальных. Если таких посылок нет, возвращает ErrParcelNotFound.
+// Задержанные Hold посылки не выдаются: обработчик не сможет их продвинуть.
+// Посылку не блокирует, для раздачи посылок нескольким обработчикам есть ClaimNextRegistered.
+func (s ParcelStore) GetNextByPriority(status string) (p Parcel, err error) {
+	span := s.startSpan("GetNextByPriority")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcel("status = ? AND on_hold = 0 AND deleted_at IS NULL ORDER BY priority DESC, created_at, number LIMIT 1", status)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetNextByPriority проверяет порядок выдачи посылок по срочности и времени создания
+func TestGetNextByPriority(t *testing.T) {
+	// prepare
+	// в своей БД посылки других тестов не перебивают срочность
+	store := newTempStore(t)
+	priority := 1
+
+	older := getTestParcel()
+	older.Priority = priority
+	older.CreatedAt = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	newer := getTestParcel()
+	newer.Priority = priority
+
+	urgent := getTestParcel()
+	urgent.Priority = priority + 1
+
+	// add
+	olderID, err := store.Add(older)
+	require.NoError(t, err)
+	newerID, err := store.Add(newer)
+	require.NoError(t, err)
+	urgentID, err := store.Add(urgent)
+	require.NoError(t, err)
+
+	// check
+	next, err := store.GetNextByPriority(ParcelStatusRegistered)
+	require.NoError(t, err)
+	require.Equal(t, urgentID, next.Number)
+	require.Equal(t, urgent.Priority, next.Priority)
+
+	require.NoError(t, store.SetStatus(urgentID, ParcelStatusSent))
+	next, err = store.GetNextByPriority(ParcelStatusRegistered)
+	require.NoError(t, err)
+	require.Equal(t, olderID, next.Number)
+
+	// задержанная посылка пропускается
+	require.NoError(t, store.Hold(olderID, "проверка"))
+	next, err = store.GetNextByPriority(ParcelStatusRegistered)
+	require.NoError(t, err)
+	require.Equal(t, newerID, next.Number)
+	require.NoError(t, store.Release(olderID))
+
+	require.NoError(t, store.SetStatus(olderID, ParcelStatusSent))
+	next, err = store.GetNextByPriority(ParcelStatusRegistered)
+	require.NoError(t, err)
+	require.Equal(t, newerID, next.Number)
+
+	_, err = store.GetNextByPriority("unknown")
+	require.ErrorIs(t, err, ErrParcelNotFound)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Код ParcelProto в parcel.pb.go сгенерирован из parcel.proto:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative parcel.proto
+
+// ToProto переводит посылку в сообщение ParcelProto для gRPC.
+// Время передаётся как Timestamp, поэтому смещение часового пояса не сохраняется:
+// FromProto вернёт то же время в UTC.
+func (p Parcel) ToProto() (*ParcelProto, error) {
+	m := &ParcelProto{
+		Number:         p.Number,
+		Client:         p.Client,
+		Status:         p.Status,
+		Address:        p.Address,
+		ExternalRef:    p.ExternalRef,
+		City:           p.City,
+		Note:           p.Note,
+		PublicId:       p.PublicID,
+		SignedBy:       p.SignedBy,
+		ClientCode:     p.ClientCode,
+		ClaimedBy:      p.ClaimedBy,
+		Carrier:        p.Carrier,
+		Street:         p.Street,
+		PostalCode:     p.PostalCode,
+		Country:        p.Country,
+		Priority:       int32(p.Priority),
+		OnHold:         p.OnHold,
+		HoldReason:     p.HoldReason,
+		AddressSet:     p.AddressSet,
+		RecipientPhone: p.RecipientPhone,
+		RecipientName:  p.RecipientName,
+	}
+
+	var err error
+	if m.CreatedAt, err = protoTime(p.CreatedAt); err != nil {
+		return nil, fmt.Errorf("created at: %w", err)
+	}
+	if m.ScheduledAt, err = protoTime(p.ScheduledAt); err != nil {
+		return nil, fmt.Errorf("scheduled at: %w", err)
+	}
+	if m.DeletedAt, err = protoTime(p.DeletedAt); err != nil {
+		return nil, fmt.Errorf("deleted at: %w", err)
+	}
+
+	return m, nil
+}
+
+// FromProto заполняет посылку из сообщения ParcelProto, записанного ToProto
+func (p *Parcel) FromProto(m *ParcelProto) {
+	*p = Parcel{
+		Number:         m.GetNumber(),
+		Client:         m.GetClient(),
+		Status:         m.GetStatus(),
+		Address:        m.GetAddress(),
+		AddressSet:     m.GetAddressSet(),
+		CreatedAt:      parcelTime(m.GetCreatedAt()),
+		ExternalRef:    m.GetExternalRef(),
+		City:           m.GetCity(),
+		Note:           m.GetNote(),
+		ScheduledAt:    parcelTime(m.GetScheduledAt()),
+		DeletedAt:      parcelTime(m.GetDeletedAt()),
+		PublicID:       m.GetPublicId(),
+		SignedBy:       m.GetSignedBy(),
+		ClientCode:     m.GetClientCode(),
+		ClaimedBy:      m.GetClaimedBy(),
+		Carrier:        m.GetCarrier(),
+		Street:         m.GetStreet(),
+		PostalCode:     m.GetPostalCode(),
+		Country:        m.GetCountry(),
+		Priority:       int(m.GetPriority()),
+		OnHold:         m.GetOnHold(),
+		HoldReason:     m.GetHoldReason(),
+		RecipientPhone: m.GetRecipientPhone(),
+		RecipientName:  m.GetRecipientName(),
+	}
+}
+
+// protoTime переводит время RFC3339 в Timestamp, пустая строка — отсутствующее поле
+func protoTime(s string) (*timestamppb.Timestamp, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return timestamppb.New(t), nil
+}
+
+// parcelTime переводит Timestamp во время RFC3339 в UTC, отсутствующее поле — в пустую строку
+func parcelTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return formatTime(ts.AsTime())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestParcelProto проверяет, что ToProto и FromProto сохраняют все поля посылки
+func TestParcelProto(t *testing.T) {
+	// prepare
+	parcel := Parcel{
+		Number:         -42,
+		Client:         1_000_000_007,
+		Status:         ParcelStatusSent,
+		Address:        "Москва, ул. Льва Толстого, 16",
+		AddressSet:     true,
+		CreatedAt:      "2024-01-02T03:04:05Z",
+		ExternalRef:    "order_1",
+		City:           "Москва",
+		Note:           "позвонить за час",
+		ScheduledAt:    "2024-01-03T07:00:00Z",
+		DeletedAt:      "2024-02-01T00:00:00Z",
+		PublicID:       RandomPublicID(),
+		SignedBy:       "Иванов И. И.",
+		ClientCode:     "ACME-42",
+		ClaimedBy:      "worker-1",
+		Carrier:        "СДЭК",
+		Street:         "ул. Льва Толстого, 16",
+		PostalCode:     "119021",
+		Country:        "Россия",
+		Priority:       2,
+		OnHold:         true,
+		HoldReason:     "проверка таможни",
+		RecipientPhone: "+79123456789",
+		RecipientName:  "Петров П. П.",
+	}
+	// новое поле Parcel нужно добавить в parcel.proto, ToProto, FromProto и сюда
+	v := reflect.ValueOf(parcel)
+	for i := 0; i < v.NumField(); i++ {
+		require.False(t, v.Field(i).IsZero(), "field %s is not set", v.Type().Field(i).Name)
+	}
+
+	// check
+	m, err := parcel.ToProto()
+	require.NoError(t, err)
+	data, err := proto.Marshal(m)
+	require.NoError(t, err)
+
+	var received ParcelProto
+	require.NoError(t, proto.Unmarshal(data, &received))
+	var decoded Parcel
+	decoded.FromProto(&received)
+	require.Equal(t, parcel, decoded)
+
+	// время со смещением приходит в UTC, незаданное время остаётся незаданным
+	m, err = Parcel{ScheduledAt: "2024-01-03T10:00:00+03:00"}.ToProto()
+	require.NoError(t, err)
+	require.Nil(t, m.CreatedAt)
+	decoded.FromProto(m)
+	require.Equal(t, "2024-01-03T07:00:00Z", decoded.ScheduledAt)
+	require.Empty(t, decoded.CreatedAt)
+
+	_, err = Parcel{CreatedAt: "вчера"}.ToProto()
+	require.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RandomPublicID генератор для StoreConfig.IDGenerator в духе ULID:
+// 48 бит времени в миллисекундах и 80 случайных бит, 32 шестнадцатеричных символа.
+// Идентификаторы, выданные позже, сортируются как строки после ранних.
+func RandomPublicID() string {
+	var b [16]byte
+
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b[:])
+}
+
+// GetByPublicID возвращает посылку по внешнему идентификатору
+func (s ParcelStore) GetByPublicID(publicID string) (p Parcel, err error) {
+	span := s.startSpan("GetByPublicID")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcel("public_id = ? AND deleted_at IS NULL", publicID)
+}
+
+// NumberByPublicID возвращает внутренний номер посылки по внешнему идентификатору,
+// чтобы вызвать остальные методы хранилища (SetStatus, SetAddress, Delete и т.д.)
+func (s ParcelStore) NumberByPublicID(publicID string) (number int64, err error) {
+	span := s.startSpan("NumberByPublicID")
+	defer func() {
+		span.SetAttributes(attribute.Int64(attrParcelNumber, number))
+		endSpan(span, err)
+	}()
+
+	p, err := s.GetByPublicID(publicID)
+	if err != nil {
+		return 0, err
+	}
+
+	return p.Number, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPublicID проверяет выдачу внешних идентификаторов и поиск по ним
+func TestPublicID(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStoreWithConfig(db, StoreConfig{IDGenerator: RandomPublicID})
+	parcel := getTestParcel()
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Len(t, stored.PublicID, 32)
+
+	byPublicID, err := store.GetByPublicID(stored.PublicID)
+	require.NoError(t, err)
+	require.Equal(t, stored, byPublicID)
+
+	number, err := store.NumberByPublicID(stored.PublicID)
+	require.NoError(t, err)
+	require.Equal(t, id, number)
+
+	_, err = store.GetByPublicID(stored.PublicID + "0")
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	// заданный вручную идентификатор не перезаписывается
+	parcel.PublicID = RandomPublicID()
+	id, err = store.Add(parcel)
+	require.NoError(t, err)
+
+	number, err = store.NumberByPublicID(parcel.PublicID)
+	require.NoError(t, err)
+	require.Equal(t, id, number)
+
+	// без генератора PublicID остаётся пустым
+	id, err = NewParcelStore(db).Add(getTestParcel())
+	require.NoError(t, err)
+
+	stored, err = store.Get(id)
+	require.NoError(t, err)
+	require.Empty(t, stored.PublicID)
+}
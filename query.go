@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrInvalidSortColumn возвращается, если сортировку просят по колонке не из sortableColumns
+var ErrInvalidSortColumn = errors.New("invalid sort column")
+
+// ErrInvalidRange начало диапазона номеров больше его конца
+var ErrInvalidRange = errors.New("invalid number range")
+
+// sortableColumns колонки, по которым разрешено сортировать в Query.
+// Имена колонок подставляются в ORDER BY как есть, поэтому список закрытый.
+var sortableColumns = map[string]bool{
+	"number":     true,
+	"client":     true,
+	"status":     true,
+	"address":    true,
+	"city":       true,
+	"created_at": true,
+	"priority":   true,
+}
+
+// SortField колонка сортировки
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// QueryOptions условия выборки Query. Нулевые значения фильтров не ограничивают выборку.
+type QueryOptions struct {
+	Client int64
+	Status string
+	Limit  int
+	Offset int
+	// Sort сортировка по нескольким колонкам по порядку, по умолчанию по number
+	Sort []SortField
+}
+
+// where возвращает условие WHERE и его параметры.
+// Мягко удалённые посылки не выбираются никогда, черновики — только если о них явно просят через Status.
+func (o QueryOptions) where() (string, []any) {
+	conds := []string{"deleted_at IS NULL"}
+	var args []any
+
+	if o.Client != 0 {
+		conds = append(conds, "client = ?")
+		args = append(args, o.Client)
+	}
+	if o.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, o.Status)
+	} else {
+		conds = append(conds, "status <> ?")
+		args = append(args, ParcelStatusDraft)
+	}
+
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// orderBy собирает ORDER BY из Sort, проверяя колонки по sortableColumns.
+// Если number среди колонок нет, он добавляется последним: иначе при равных значениях
+// порядок не определён и страницы Limit/Offset теряют или повторяют посылки.
+func (o QueryOptions) orderBy() (string, error) {
+	if len(o.Sort) == 0 {
+		return "ORDER BY number", nil
+	}
+
+	fields := make([]string, 0, len(o.Sort)+1)
+	unique := false
+	for _, f := range o.Sort {
+		if !sortableColumns[f.Column] {
+			return "", fmt.Errorf("%w: %q", ErrInvalidSortColumn, f.Column)
+		}
+		unique = unique || f.Column == "number"
+
+		field := f.Column
+		if f.Desc {
+			field += " DESC"
+		}
+		fields = append(fields, field)
+	}
+	if !unique {
+		fields = append(fields, "number")
+	}
+
+	return "ORDER BY " + strings.Join(fields, ", "), nil
+}
+
+// Query возвращает посылки по условиям opts
+func (s ParcelStore) Query(opts QueryOptions) (res []Parcel, err error) {
+	span := s.startSpan("Query")
+	defer func() { endSpan(span, err) }()
+
+	where, args := opts.where()
+
+	orderBy, err := opts.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
+	tail := where + " " + orderBy
+	switch {
+	case opts.Limit > 0:
+		tail += " LIMIT ?"
+		args = append(args, opts.Limit)
+	case opts.Offset > 0 && s.cfg.Dialect == DialectSQLite:
+		// в SQLite OFFSET без LIMIT не допускается
+		tail += " LIMIT -1"
+	}
+	if opts.Offset > 0 {
+		tail += " OFFSET ?"
+		args = append(args, opts.Offset)
+	}
+
+	return s.queryParcels(tail, args...)
+}
+
+// CountQuery возвращает количество посылок, подходящих под фильтры opts, например для числа страниц.
+// Условия те же, что у Query, а Limit, Offset и Sort не учитываются.
+func (s ParcelStore) CountQuery(opts QueryOptions) (n int, err error) {
+	span := s.startSpan("CountQuery")
+	defer func() { endSpan(span, err) }()
+
+	where, args := opts.where()
+	err = s.read.QueryRow("SELECT COUNT(*) FROM parcel "+where, args...).Scan(&n)
+
+	return n, err
+}
+
+// GetByStatuses возвращает посылки, статус которых входит в statuses, например
+// registered и sent для «активных» посылок. Неизвестный статус — ошибка ErrUnknownStatus,
+// пустой список — пустой результат без запроса к базе.
+func (s ParcelStore) GetByStatuses(statuses []string) (res []Parcel, err error) {
+	span := s.startSpan("GetByStatuses")
+	defer func() { endSpan(span, err) }()
+
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, len(statuses))
+	for i, status := range statuses {
+		if !knownStatuses[status] {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, status)
+		}
+		args[i] = status
+	}
+
+	return s.queryParcels("WHERE status IN ("+placeholders(len(args))+") AND deleted_at IS NULL ORDER BY number", args...)
+}
+
+// GetByNumberRange возвращает посылки с номерами от from до to включительно в порядке номеров,
+// например для выгрузки, где каждый обработчик берёт свой непрерывный диапазон.
+// Если from больше to — ошибка ErrInvalidRange.
+func (s ParcelStore) GetByNumberRange(from, to int64) (res []Parcel, err error) {
+	span := s.startSpan("GetByNumberRange", attribute.Int64("parcel.number_from", from), attribute.Int64("parcel.number_to", to))
+	defer func() { endSpan(span, err) }()
+
+	if from > to {
+		return nil, fmt.Errorf("%w: %d > %d", ErrInvalidRange, from, to)
+	}
+
+	return s.queryParcels("WHERE number BETWEEN ? AND ? AND deleted_at IS NULL ORDER BY number", from, to)
+}
+
+// GetGroupedByStatus возвращает посылки клиента, разложенные по статусам, одним запросом.
+// Условия те же, что у GetByClient; статусов без посылок в результате нет.
+func (s ParcelStore) GetGroupedByStatus(client int64) (res map[string][]Parcel, err error) {
+	span := s.startSpan("GetGroupedByStatus", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	parcels, err := s.queryParcels("WHERE client = ? AND deleted_at IS NULL AND status <> ? ORDER BY number", client, ParcelStatusDraft)
+	if err != nil {
+		return nil, err
+	}
+
+	res = make(map[string][]Parcel)
+	for _, p := range parcels {
+		res[p.Status] = append(res[p.Status], p)
+	}
+
+	return res, nil
+}
+
+// MonthCount количество посылок за календарный месяц
+type MonthCount struct {
+	Month   string // "2006-01", по UTC
+	Parcels int
+}
+
+// MonthlyVolumeByClient возвращает количество посылок клиента (без черновиков и удалённых)
+// по месяцам создания за последние months месяцев, включая текущий, от старых к новым.
+// Месяцы без посылок тоже есть в результате, с нулём.
+func (s ParcelStore) MonthlyVolumeByClient(client int64, months int) (res []MonthCount, err error) {
+	span := s.startSpan("MonthlyVolumeByClient", attribute.Int64(attrParcelClient, client))
+	defer func() { endSpan(span, err) }()
+
+	if months <= 0 {
+		return nil, nil
+	}
+
+	now := s.cfg.Now().UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1-months, 0)
+
+	// месяц UTC берётся из created_unix, а не из первых символов created_at:
+	// строка, записанная в обход Add, может хранить created_at со смещением часового пояса
+	month := "strftime('%Y-%m', created_unix, 'unixepoch')"
+	if s.cfg.Dialect == DialectPostgres {
+		month = "to_char(to_timestamp(created_unix) AT TIME ZONE 'UTC', 'YYYY-MM')"
+	}
+	cond, arg := s.createdAtCond(">=", first)
+
+	rows, err := s.read.Query(`SELECT `+month+`, COUNT(*) FROM parcel
+		WHERE client = ? AND `+cond+` AND status <> ? AND deleted_at IS NULL
+		GROUP BY `+month,
+		client, arg, ParcelStatusDraft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var month string
+		var n int
+		if err := rows.Scan(&month, &n); err != nil {
+			return nil, err
+		}
+		counts[month] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res = make([]MonthCount, months)
+	for i := range res {
+		month := first.AddDate(0, i, 0).Format("2006-01")
+		res[i] = MonthCount{Month: month, Parcels: counts[month]}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestQuerySort проверяет сортировку по нескольким колонкам
+func TestQuerySort(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	client := randRange.Int63n(10_000_000)
+
+	// add
+	var numbers []int64
+	for i := 0; i < 3; i++ {
+		parcel := getTestParcel()
+		parcel.Client = client
+		id, err := store.Add(parcel)
+		require.NoError(t, err)
+		numbers = append(numbers, id)
+	}
+	require.NoError(t, store.SetStatus(numbers[1], ParcelStatusSent))
+
+	// query
+	// registered < sent, внутри статуса — по убыванию номера
+	parcels, err := store.Query(QueryOptions{
+		Client: client,
+		Sort:   []SortField{{Column: "status"}, {Column: "number", Desc: true}},
+	})
+	require.NoError(t, err)
+
+	var got []int64
+	for _, p := range parcels {
+		got = append(got, p.Number)
+	}
+	require.Equal(t, []int64{numbers[2], numbers[0], numbers[1]}, got)
+
+	// limit, offset
+	parcels, err = store.Query(QueryOptions{Client: client, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, parcels, 2)
+	require.Equal(t, numbers[1], parcels[0].Number)
+
+	// количество без учёта страниц
+	n, err := store.CountQuery(QueryOptions{Client: client, Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	n, err = store.CountQuery(QueryOptions{Client: client, Status: ParcelStatusSent})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	// при равном статусе страницы идут по номеру и не повторяются
+	got = got[:0]
+	for offset := 0; offset < 3; offset++ {
+		parcels, err = store.Query(QueryOptions{Client: client, Limit: 1, Offset: offset, Sort: []SortField{{Column: "status"}}})
+		require.NoError(t, err)
+		require.Len(t, parcels, 1)
+		got = append(got, parcels[0].Number)
+	}
+	require.Equal(t, []int64{numbers[0], numbers[2], numbers[1]}, got)
+
+	// недопустимая колонка
+	_, err = store.Query(QueryOptions{Sort: []SortField{{Column: "number; DROP TABLE parcel"}}})
+	require.ErrorIs(t, err, ErrInvalidSortColumn)
+}
+
+// TestGetByStatuses проверяет выборку по набору статусов
+func TestGetByStatuses(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	registered, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	sent, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(sent, ParcelStatusSent))
+	delivered, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(delivered, ParcelStatusSent))
+	require.NoError(t, store.SetStatus(delivered, ParcelStatusDelivered))
+
+	// check
+	parcels, err := store.GetByStatuses([]string{ParcelStatusRegistered, ParcelStatusSent})
+	require.NoError(t, err)
+
+	got := make(map[int64]string)
+	for _, p := range parcels {
+		require.Contains(t, []string{ParcelStatusRegistered, ParcelStatusSent}, p.Status)
+		got[p.Number] = p.Status
+	}
+	require.Equal(t, ParcelStatusRegistered, got[registered])
+	require.Equal(t, ParcelStatusSent, got[sent])
+	require.NotContains(t, got, delivered)
+
+	parcels, err = store.GetByStatuses(nil)
+	require.NoError(t, err)
+	require.Empty(t, parcels)
+
+	_, err = store.GetByStatuses([]string{ParcelStatusSent, "active"})
+	require.ErrorIs(t, err, ErrUnknownStatus)
+}
+
+// TestGetGroupedByStatus проверяет группировку посылок клиента по статусам
+func TestGetGroupedByStatus(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	client := randRange.Int63n(10_000_000)
+
+	// add
+	numbers := make([]int64, 3)
+	for i := range numbers {
+		parcel := getTestParcel()
+		parcel.Client = client
+		numbers[i], err = store.Add(parcel)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.SetStatus(numbers[1], ParcelStatusSent))
+
+	// check
+	groups, err := store.GetGroupedByStatus(client)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	require.Len(t, groups[ParcelStatusRegistered], 2)
+	require.Equal(t, numbers[0], groups[ParcelStatusRegistered][0].Number)
+	require.Equal(t, numbers[2], groups[ParcelStatusRegistered][1].Number)
+	require.Len(t, groups[ParcelStatusSent], 1)
+	require.Equal(t, numbers[1], groups[ParcelStatusSent][0].Number)
+	require.NotContains(t, groups, ParcelStatusDelivered)
+}
+
+// TestGetByNumberRange проверяет выборку посылок по диапазону номеров
+func TestGetByNumberRange(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	numbers := make([]int64, 3)
+	for i := range numbers {
+		numbers[i], err = store.Add(getTestParcel())
+		require.NoError(t, err)
+	}
+
+	// check
+	parcels, err := store.GetByNumberRange(numbers[0], numbers[2])
+	require.NoError(t, err)
+	got := make([]int64, len(parcels))
+	for i, p := range parcels {
+		got[i] = p.Number
+	}
+	require.Equal(t, numbers, got)
+
+	parcels, err = store.GetByNumberRange(numbers[1], numbers[1])
+	require.NoError(t, err)
+	require.Len(t, parcels, 1)
+	require.Equal(t, numbers[1], parcels[0].Number)
+
+	_, err = store.GetByNumberRange(numbers[2], numbers[0])
+	require.ErrorIs(t, err, ErrInvalidRange)
+}
+
+// TestMonthlyVolumeByClient проверяет помесячное количество посылок клиента с пустыми месяцами
+func TestMonthlyVolumeByClient(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	store := NewParcelStoreWithConfig(db, StoreConfig{Now: func() time.Time { return now }})
+	client := randRange.Int63n(10_000_000)
+
+	// add
+	for _, createdAt := range []string{"2024-06-01T00:00:00Z", "2024-06-10T10:00:00Z", "2024-04-20T00:00:00Z", "2024-03-31T23:59:59Z",
+		// по UTC это 30 апреля и 1 апреля
+		"2024-05-01T01:00:00+03:00", "2024-03-31T23:00:00-02:00"} {
+		parcel := getTestParcel()
+		parcel.Client = client
+		parcel.CreatedAt = createdAt
+		_, err := store.Add(parcel)
+		require.NoError(t, err)
+	}
+
+	// check
+	volume, err := store.MonthlyVolumeByClient(client, 3)
+	require.NoError(t, err)
+	want := []MonthCount{
+		{Month: "2024-04", Parcels: 3},
+		{Month: "2024-05", Parcels: 0},
+		{Month: "2024-06", Parcels: 2},
+	}
+	require.Equal(t, want, volume)
+
+	epochStore := NewParcelStoreWithConfig(db, StoreConfig{Now: func() time.Time { return now }, EpochTimestamps: true})
+	volume, err = epochStore.MonthlyVolumeByClient(client, 3)
+	require.NoError(t, err)
+	require.Equal(t, want, volume)
+
+	volume, err = store.MonthlyVolumeByClient(client, 0)
+	require.NoError(t, err)
+	require.Empty(t, volume)
+}
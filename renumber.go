@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNumberTaken новый номер посылки уже занят другой строкой parcel, в том числе мягко удалённой, или архивной посылкой
+var ErrNumberTaken = errors.New("parcel number is already taken")
+
+// numberTables таблицы, ссылающиеся на parcel.number, которые ChangeNumber переносит вместе с посылкой.
+// Журнала аудита здесь нет: он только пополняется, смена номера записывается в него отдельной записью.
+var numberTables = []string{"parcel_status_history", "parcel_delivery_attempt", "parcel_tag", "parcel_duplicate"}
+
+// ChangeNumber меняет номер посылки с oldNumber на newNumber в одной транзакции вместе с историей статусов,
+// попытками доставки, метками и ссылками дублей. Прежние записи журнала аудита остаются
+// под старым номером, а сама смена записывается под новым. Новый номер должен быть свободен и в parcel, и в parcel_archive, иначе ErrNumberTaken.
+// Последовательность номеров не сдвигается: в PostgreSQL новый номер, больше выданных,
+// позже может столкнуться с автоматически выданным.
+func (s ParcelStore) ChangeNumber(oldNumber, newNumber int64) (err error) {
+	span := s.startSpan("ChangeNumber", attribute.Int64(attrParcelNumber, oldNumber), attribute.Int64("parcel.new_number", newNumber))
+	defer func() { endSpan(span, err) }()
+
+	if oldNumber == newNumber {
+		return nil
+	}
+
+	if s.tracksChanges() {
+		_, err = s.audited("change number", oldNumber, func(tx ParcelStore) (int64, error) {
+			return newNumber, tx.ChangeNumber(oldNumber, newNumber)
+		})
+		return err
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		if _, err := tx.GetForUpdate(oldNumber); err != nil {
+			return err
+		}
+
+		_, err := tx.queryParcel("number = ?", newNumber)
+		if err == nil {
+			return ErrNumberTaken
+		}
+		if !errors.Is(err, ErrParcelNotFound) {
+			return err
+		}
+		// номер архивной посылки тоже занят: иначе GetAnywhere и история смешают две посылки
+		var archived int
+		err = tx.db.QueryRow("SELECT 1 FROM parcel_archive WHERE number = ?", newNumber).Scan(&archived)
+		if err == nil {
+			return ErrNumberTaken
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if _, err := tx.db.Exec("UPDATE parcel SET number = ? WHERE number = ?", newNumber, oldNumber); err != nil {
+			return err
+		}
+		for _, table := range numberTables {
+			if _, err := tx.db.Exec("UPDATE "+table+" SET number = ? WHERE number = ?", newNumber, oldNumber); err != nil {
+				return err
+			}
+		}
+		// ссылки дублей на эту посылку тоже переносятся
+		if _, err := tx.db.Exec("UPDATE parcel_duplicate SET duplicate_of = ? WHERE duplicate_of = ?", newNumber, oldNumber); err != nil {
+			return err
+		}
+		// payload событий outbox не меняется, но PurgeOlderThan должен найти их под новым номером
+		_, err = tx.db.Exec("UPDATE parcel_outbox SET number = ? WHERE number = ?", newNumber, oldNumber)
+		return err
+	})
+}
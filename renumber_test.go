@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestChangeNumber проверяет перенумерацию посылки вместе со связанными данными
+func TestChangeNumber(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	require.NoError(t, store.AddTag(id, "fragile"))
+	require.NoError(t, store.RecordAttempt(id, AttemptOutcomeFailed, ""))
+
+	taken, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	require.ErrorIs(t, store.ChangeNumber(id, taken), ErrNumberTaken)
+
+	newNumber := 1_000_000_000 + randRange.Int63n(1_000_000_000)
+	require.NoError(t, store.ChangeNumber(id, newNumber))
+
+	_, err = store.Get(id)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	detail, err := store.GetFull(newNumber)
+	require.NoError(t, err)
+	require.Equal(t, newNumber, detail.Parcel.Number)
+	require.Equal(t, ParcelStatusSent, detail.Parcel.Status)
+	require.Equal(t, []string{"fragile"}, detail.Tags)
+	require.Len(t, detail.History, 2)
+	require.Len(t, detail.Attempts, 1)
+
+	require.ErrorIs(t, store.ChangeNumber(id, newNumber+1), ErrParcelNotFound)
+}
+
+// TestChangeNumberArchived проверяет, что номер архивной посылки считается занятым
+func TestChangeNumberArchived(t *testing.T) {
+	// prepare
+	store := newTempStore(t)
+
+	// add
+	archived, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.RepairStatus(archived, ParcelStatusDelivered))
+	require.NoError(t, store.Archive(archived))
+
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	require.ErrorIs(t, store.ChangeNumber(id, archived), ErrNumberTaken)
+
+	_, err = store.Get(id)
+	require.NoError(t, err)
+}
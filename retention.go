@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// PurgeOlderThan безвозвратно удаляет посылки, созданные раньше, чем age назад, вместе
+// с историей статусов, попытками доставки, метками и событиями outbox — для соблюдения срока хранения данных.
+// Архивные посылки из parcel_archive удаляются по тем же правилам: архив не продлевает срок хранения.
+// Записи журнала аудита остаются, но снимки посылки в них (Before, After) стираются, в том числе
+// в записях под прежними номерами до ChangeNumber; сама очистка пишется в журнал записью "purge" без данных посылки.
+// Мягко удалённые посылки тоже удаляются, а посылки без CreatedAt (например, черновики) и задержанные Hold — нет. С StoreConfig.PurgeTerminalOnly удаляются только
+// посылки в конечных статусах. Всё выполняется одной транзакцией, возвращается количество удалённых посылок.
+func (s ParcelStore) PurgeOlderThan(age time.Duration) (n int, err error) {
+	span := s.startSpan("PurgeOlderThan")
+	defer func() { endSpan(span, err) }()
+
+	before := s.cfg.Now().Add(-age)
+	cond, cutoff := s.createdAtCond("<", before)
+	where := "WHERE created_at <> '' AND on_hold = 0 AND " + cond
+	args := []any{cutoff}
+	// в parcel_archive нет created_unix, но created_at там уже в UTC и сравнивается как строка
+	archiveWhere := "WHERE created_at <> '' AND on_hold = 0 AND created_at < ?"
+	archiveArgs := []any{formatTime(before)}
+	if s.cfg.PurgeTerminalOnly {
+		statuses := terminalStatuses()
+		where += " AND status IN (" + placeholders(len(statuses)) + ")"
+		args = append(args, statuses...)
+		archiveWhere += " AND status IN (" + placeholders(len(statuses)) + ")"
+		archiveArgs = append(archiveArgs, statuses...)
+	}
+	numbers := "SELECT number FROM parcel " + where + " UNION ALL SELECT number FROM parcel_archive " + archiveWhere
+	numbersArgs := append(append([]any{}, args...), archiveArgs...)
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		// записи журнала аудита не удаляются, а удаление каждой посылки пишется в него и в outbox
+		// без её данных, чтобы они не пережили удаление
+		var purged []int64
+		if tx.tracksChanges() {
+			var err error
+			if purged, err = tx.selectNumbers(numbers, numbersArgs...); err != nil {
+				return err
+			}
+		}
+
+		// прежние события outbox содержат данные посылки, поэтому удаляются и неопубликованные
+		for _, table := range append(numberTables, "parcel_outbox") {
+			if _, err := tx.db.Exec("DELETE FROM "+table+" WHERE number IN ("+numbers+")", numbersArgs...); err != nil {
+				return err
+			}
+		}
+		// снимки в журнале аудита содержат данные посылки и стираются, включая записи под прежними номерами
+		previous, err := tx.previousNumbers(numbers, numbersArgs)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.db.Exec("UPDATE parcel_audit SET before = NULL, after = NULL WHERE number IN ("+numbers+")", numbersArgs...); err != nil {
+			return err
+		}
+		if len(previous) > 0 {
+			if _, err := tx.db.Exec("UPDATE parcel_audit SET before = NULL, after = NULL WHERE number IN ("+placeholders(len(previous))+")", previous...); err != nil {
+				return err
+			}
+		}
+
+		// связи дублей, указывающие на удаляемую посылку, тоже удаляются
+		if _, err := tx.db.Exec("DELETE FROM parcel_duplicate WHERE duplicate_of IN ("+numbers+")", numbersArgs...); err != nil {
+			return err
+		}
+
+		n = 0
+		for _, del := range []struct {
+			query string
+			args  []any
+		}{
+			{"DELETE FROM parcel " + where, args},
+			{"DELETE FROM parcel_archive " + archiveWhere, archiveArgs},
+		} {
+			res, err := tx.db.Exec(del.query, del.args...)
+			if err != nil {
+				return err
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			n += int(affected)
+		}
+
+		for _, number := range purged {
+			if err := tx.recordChange("purge", number, sql.NullString{}, sql.NullString{}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// previousNumbers возвращает прежние номера посылок из запроса numbers: ChangeNumber оставляет
+// записи журнала аудита под старым номером, а сам старый номер есть в снимке before записи "change number".
+// Номера из цепочки нескольких смен тоже возвращаются.
+func (s ParcelStore) previousNumbers(numbers string, args []any) ([]any, error) {
+	seen := make(map[int64]bool)
+	var res []any
+	for {
+		rows, err := s.db.Query("SELECT before FROM parcel_audit WHERE operation = ? AND before IS NOT NULL AND number IN ("+numbers+")",
+			append([]any{"change number"}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		args = args[:0:0]
+		for rows.Next() {
+			var before string
+			var p Parcel
+			if err := rows.Scan(&before); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(before), &p); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !seen[p.Number] {
+				seen[p.Number] = true
+				args = append(args, p.Number)
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(args) == 0 {
+			return res, nil
+		}
+		res = append(res, args...)
+		numbers = placeholders(len(args))
+	}
+}
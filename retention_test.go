@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPurgeOlderThan проверяет удаление старых посылок вместе со связанными данными
+func TestPurgeOlderThan(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(1990, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewParcelStoreWithConfig(db, StoreConfig{Now: func() time.Time { return now }})
+
+	add := func(createdAt time.Time, status string) int64 {
+		parcel := getTestParcel()
+		parcel.CreatedAt = formatTime(createdAt)
+		id, err := store.Add(parcel)
+		require.NoError(t, err)
+		require.NoError(t, store.RepairStatus(id, status))
+		require.NoError(t, store.AddTag(id, "retention"))
+		return id
+	}
+
+	// add
+	oldDelivered := add(now.AddDate(0, -3, 0), ParcelStatusDelivered)
+	oldSent := add(now.AddDate(0, -3, 0), ParcelStatusSent)
+	recent := add(now, ParcelStatusDelivered)
+	// recent записан дублем oldDelivered, связь должна уйти вместе с oldDelivered
+	_, err = db.Exec("INSERT INTO parcel_duplicate (number, duplicate_of, created_at) VALUES (?, ?, ?)", recent, oldDelivered, formatTime(now))
+	require.NoError(t, err)
+
+	// check
+	terminalOnly := NewParcelStoreWithConfig(db, StoreConfig{Now: store.cfg.Now, PurgeTerminalOnly: true})
+	// в общей базе могут быть и другие старые посылки
+	n, err := terminalOnly.PurgeOlderThan(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 1)
+
+	_, err = store.Get(oldDelivered)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+	tags, err := store.GetTags(oldDelivered)
+	require.NoError(t, err)
+	require.Empty(t, tags)
+	history, err := store.queryStatusChanges("WHERE number = ?", oldDelivered)
+	require.NoError(t, err)
+	require.Empty(t, history)
+	var links int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM parcel_duplicate WHERE duplicate_of = ?", oldDelivered).Scan(&links))
+	require.Zero(t, links)
+
+	_, err = store.Get(oldSent)
+	require.NoError(t, err)
+
+	n, err = store.PurgeOlderThan(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 1)
+
+	_, err = store.Get(oldSent)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+	_, err = store.Get(recent)
+	require.NoError(t, err)
+}
+
+// TestPurgeArchiveAndOutbox проверяет удаление архивных посылок и их событий outbox
+func TestPurgeArchiveAndOutbox(t *testing.T) {
+	// prepare
+	now := time.Date(1990, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := newTempStore(t, WithClock(func() time.Time { return now }), WithOutbox())
+
+	parcel := getTestParcel()
+	parcel.CreatedAt = formatTime(now.AddDate(0, -3, 0))
+
+	// add
+	archived, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.RepairStatus(archived, ParcelStatusDelivered))
+	require.NoError(t, store.Archive(archived))
+	renumbered, err := store.Add(parcel)
+	require.NoError(t, err)
+	// события, записанные до смены номера, тоже должны удалиться
+	require.NoError(t, store.ChangeNumber(renumbered, renumbered+1000))
+
+	// check
+	n, err := store.PurgeOlderThan(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	_, _, err = store.GetAnywhere(archived)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	// от удалённых посылок в outbox остаются только события "purge"
+	all, err := store.FetchUnpublished(100)
+	require.NoError(t, err)
+	var types []string
+	for _, e := range all {
+		types = append(types, e.Type)
+		require.NotContains(t, e.Payload, "before")
+	}
+	require.Equal(t, []string{"purge", "purge"}, types)
+}
+
+// TestPurgeRedactsAudit проверяет, что после удаления в журнале аудита не остаётся данных посылки
+func TestPurgeRedactsAudit(t *testing.T) {
+	// prepare
+	now := time.Date(1990, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := newTempStore(t, WithClock(func() time.Time { return now }), WithAudit())
+
+	parcel := getTestParcel()
+	parcel.CreatedAt = formatTime(now.AddDate(0, -3, 0))
+	parcel.RecipientName = "Иван Петров"
+
+	// add
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+	// записи до смены номера остаются под прежним номером
+	require.NoError(t, store.ChangeNumber(id, id+1000))
+	require.NoError(t, store.ChangeNumber(id+1000, id+2000))
+
+	// check
+	n, err := store.PurgeOlderThan(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	var operations []string
+	for _, number := range []int64{id, id + 1000, id + 2000} {
+		trail, err := store.GetAuditTrail(number)
+		require.NoError(t, err)
+		for _, e := range trail {
+			operations = append(operations, e.Operation)
+			require.Empty(t, e.Before)
+			require.Empty(t, e.After)
+		}
+	}
+	require.Equal(t, []string{"add", "set status", "change number", "change number", "purge"}, operations)
+}
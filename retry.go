@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// IsRetryableSQLite считает временными ошибки SQLITE_BUSY и SQLITE_LOCKED
+// (база или таблица заблокирована другой транзакцией), включая их расширенные коды
+func IsRetryableSQLite(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+
+	switch se.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+
+	return false
+}
+
+// IsRetryablePostgres считает временными ошибки сериализации (40001), взаимоблокировки (40P01)
+// и разрыв соединения. Код SQLSTATE берётся через метод SQLState(), который есть
+// у ошибок pgx и lib/pq, поэтому зависимости от драйвера нет.
+func IsRetryablePostgres(err error) bool {
+	var se interface{ SQLState() string }
+	if errors.As(err, &se) {
+		switch se.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+
+	return errors.Is(err, syscall.ECONNRESET)
+}
+
+// retry выполняет op и повторяет её, пока ошибка временная по cfg.IsRetryable,
+// но не больше cfg.MaxRetries раз; пауза между попытками удваивается
+func (s ParcelStore) retry(op func() error) error {
+	delay := s.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= s.cfg.MaxRetries || !s.cfg.IsRetryable(err) {
+			return err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+// CurrentSchemaVersion версия схемы БД, с которой работает этот код.
+// Применённые версии записываются в таблицу schema_migrations.
+const CurrentSchemaVersion = 21
+
+// sqliteSchema схема БД версии CurrentSchemaVersion для InitSchema, та же, что в tracker.db.
+// Новую миграцию нужно отражать и здесь.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS parcel
+(
+    number       integer
+        constraint parcel_pk
+            primary key autoincrement,
+    client       integer      not null,
+    status       VARCHAR(128) not null,
+    address      VARCHAR(512),
+    created_at   text         not null,
+    external_ref VARCHAR(128),
+    city         VARCHAR(128),
+    note         TEXT,
+    scheduled_at text,
+    deleted_at   text,
+    public_id    TEXT,
+    signed_by    TEXT,
+    client_code  TEXT,
+    claimed_by   TEXT,
+    carrier      TEXT,
+    street       VARCHAR(256),
+    postal_code  VARCHAR(16),
+    country      VARCHAR(64),
+    surveyed_at  VARCHAR(32),
+    created_unix INTEGER,
+    priority     INTEGER      NOT NULL DEFAULT 0,
+    on_hold      INTEGER      NOT NULL DEFAULT 0,
+    hold_reason  TEXT,
+    recipient_phone VARCHAR(32),
+    recipient_name VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS parcel_status_history
+(
+    id         integer
+        constraint parcel_status_history_pk
+            primary key autoincrement,
+    number     integer      not null,
+    old_status VARCHAR(128),
+    new_status VARCHAR(128) not null,
+    changed_at text         not null
+);
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER NOT NULL PRIMARY KEY,
+    applied_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parcel_delivery_attempt (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    number INTEGER NOT NULL,
+    outcome TEXT NOT NULL,
+    note TEXT,
+    attempted_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parcel_tag (
+    number INTEGER NOT NULL,
+    tag VARCHAR(64) NOT NULL,
+    PRIMARY KEY (number, tag)
+);
+
+CREATE TABLE IF NOT EXISTS parcel_audit (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    number INTEGER NOT NULL,
+    operation TEXT NOT NULL,
+    before TEXT,
+    after TEXT,
+    actor TEXT,
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parcel_archive (
+    number INTEGER NOT NULL PRIMARY KEY,
+    client INTEGER NOT NULL,
+    status VARCHAR(128) NOT NULL,
+    address VARCHAR(512),
+    created_at TEXT NOT NULL,
+    external_ref VARCHAR(128),
+    city VARCHAR(128),
+    note TEXT,
+    scheduled_at TEXT,
+    deleted_at TEXT,
+    public_id TEXT,
+    signed_by TEXT,
+    client_code TEXT,
+    claimed_by TEXT,
+    carrier TEXT,
+    street VARCHAR(256),
+    postal_code VARCHAR(16),
+    country VARCHAR(64),
+    priority INTEGER NOT NULL DEFAULT 0,
+    archived_at TEXT NOT NULL,
+    on_hold INTEGER NOT NULL DEFAULT 0,
+    hold_reason TEXT,
+    recipient_phone VARCHAR(32),
+    recipient_name VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS parcel_duplicate (
+    number INTEGER NOT NULL PRIMARY KEY,
+    duplicate_of INTEGER NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parcel_outbox (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    published INTEGER NOT NULL DEFAULT 0,
+    number INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS parcel_external_ref_index ON parcel (external_ref);
+CREATE INDEX IF NOT EXISTS parcel_city_index ON parcel (city);
+CREATE INDEX IF NOT EXISTS parcel_status_scheduled_at_index ON parcel (status, scheduled_at);
+CREATE UNIQUE INDEX IF NOT EXISTS parcel_public_id_index ON parcel (public_id);
+CREATE INDEX IF NOT EXISTS parcel_client_code_index ON parcel (client_code);
+CREATE INDEX IF NOT EXISTS parcel_status_created_at_index ON parcel (status, created_at);
+CREATE INDEX IF NOT EXISTS parcel_carrier_index ON parcel (carrier);
+CREATE INDEX IF NOT EXISTS parcel_postal_code_index ON parcel (postal_code);
+CREATE INDEX IF NOT EXISTS parcel_country_index ON parcel (country);
+CREATE INDEX IF NOT EXISTS parcel_created_unix_index ON parcel (created_unix);
+CREATE INDEX IF NOT EXISTS parcel_deleted_at_index ON parcel (deleted_at);
+CREATE INDEX IF NOT EXISTS parcel_status_priority_created_at_index ON parcel (status, priority, created_at);
+CREATE INDEX IF NOT EXISTS parcel_recipient_phone_index ON parcel (recipient_phone);
+CREATE INDEX IF NOT EXISTS parcel_status_history_number_index ON parcel_status_history (number);
+CREATE INDEX IF NOT EXISTS parcel_status_history_new_status_index ON parcel_status_history (new_status, changed_at);
+CREATE INDEX IF NOT EXISTS parcel_delivery_attempt_number_index ON parcel_delivery_attempt (number, attempted_at);
+CREATE INDEX IF NOT EXISTS parcel_tag_tag_index ON parcel_tag (tag);
+CREATE INDEX IF NOT EXISTS parcel_audit_number_index ON parcel_audit (number);
+CREATE INDEX IF NOT EXISTS parcel_audit_operation_index ON parcel_audit (operation, created_at);
+CREATE INDEX IF NOT EXISTS parcel_outbox_published_index ON parcel_outbox (published, id);
+CREATE INDEX IF NOT EXISTS parcel_outbox_number_index ON parcel_outbox (number);
+CREATE INDEX IF NOT EXISTS parcel_duplicate_duplicate_of_index ON parcel_duplicate (duplicate_of);
+
+CREATE TRIGGER IF NOT EXISTS parcel_status_history_insert
+    AFTER INSERT
+    ON parcel
+BEGIN
+    INSERT INTO parcel_status_history (number, old_status, new_status, changed_at)
+    VALUES (NEW.number, NULL, NEW.status, NEW.created_at);
+END;
+
+CREATE TRIGGER IF NOT EXISTS parcel_status_history_update
+    AFTER UPDATE OF status
+    ON parcel
+    WHEN OLD.status IS NOT NEW.status
+BEGIN
+    INSERT INTO parcel_status_history (number, old_status, new_status, changed_at)
+    VALUES (NEW.number, OLD.status, NEW.status, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+END;
+
+CREATE TRIGGER IF NOT EXISTS parcel_created_unix_insert
+    AFTER INSERT
+    ON parcel
+BEGIN
+    UPDATE parcel SET created_unix = CAST(strftime('%s', NEW.created_at) AS INTEGER) WHERE number = NEW.number;
+END;
+
+CREATE TRIGGER IF NOT EXISTS parcel_created_unix_update
+    AFTER UPDATE OF created_at
+    ON parcel
+BEGIN
+    UPDATE parcel SET created_unix = CAST(strftime('%s', NEW.created_at) AS INTEGER) WHERE number = NEW.number;
+END;
+`
+
+// SchemaVersion возвращает последнюю применённую к БД версию схемы
+// или 0, если база новая и таблицы schema_migrations в ней ещё нет.
+// Приложение может сравнить её с CurrentSchemaVersion и не запускаться на старой схеме.
+func SchemaVersion(db *sql.DB) (version int, err error) {
+	span := startSpanContext(context.Background(), "SchemaVersion")
+	defer func() { endSpan(span, err) }()
+
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if isUndefinedTable(err) {
+		return 0, nil
+	}
+
+	return version, err
+}
+
+// isUndefinedTable сообщает, что запрос обратился к несуществующей таблице.
+// В PostgreSQL это SQLSTATE 42P01, в SQLite отдельного кода нет, проверяется текст ошибки.
+func isUndefinedTable(err error) bool {
+	var pe interface{ SQLState() string }
+	if errors.As(err, &pe) {
+		return pe.SQLState() == "42P01"
+	}
+
+	var se *sqlite.Error
+	return errors.As(err, &se) && strings.Contains(se.Error(), "no such table")
+}
+
+// InitSchema создаёт в пустой базе схему версии CurrentSchemaVersion и отмечает её в schema_migrations.
+// Существующие таблицы, индексы и триггеры не трогает, поэтому повторный вызов безопасен,
+// но и устаревшую схему не обновляет: её версию можно проверить через SchemaVersion.
+// Схема описана только для SQLite, для других диалектов возвращается ErrUnsupportedDialect.
+func (s ParcelStore) InitSchema() (err error) {
+	span := s.startSpan("InitSchema")
+	defer func() { endSpan(span, err) }()
+
+	if s.cfg.Dialect != DialectSQLite {
+		return fmt.Errorf("init schema: %w", ErrUnsupportedDialect)
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		if _, err := tx.db.Exec(sqliteSchema); err != nil {
+			return err
+		}
+
+		// версия записывается только в новую базу, в которой ещё нет ни одной миграции
+		_, err := tx.db.Exec("INSERT INTO schema_migrations (version, applied_at) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM schema_migrations)",
+			CurrentSchemaVersion, formatTime(tx.cfg.Now()))
+		return err
+	})
+}
+
+// Vacuum перестраивает файл SQLite, возвращая место после массовых удалений.
+// VACUUM требует монопольного доступа: пока он идёт, остальные запросы ждут или получают SQLITE_BUSY,
+// а внутри транзакции не выполняется вовсе, поэтому всегда идёт через соединение, а не через WithTx.
+// В PostgreSQL место освобождает autovacuum, там метод ничего не делает.
+func (s ParcelStore) Vacuum() (err error) {
+	span := s.startSpan("Vacuum")
+	defer func() { endSpan(span, err) }()
+
+	if s.cfg.Dialect != DialectSQLite {
+		return nil
+	}
+
+	if !s.life.enter() {
+		return ErrStoreClosed
+	}
+	defer s.life.leave()
+
+	_, err = s.conn.Exec("VACUUM")
+	return err
+}
+
+// Reindex перестраивает индексы и обновляет статистику планировщика запросов (REINDEX и ANALYZE).
+// Стоит вызывать после массовой загрузки или удаления (Import, LoadGzip, PurgeOlderThan),
+// когда запросы вроде GetByClient начинают идти по неудачному плану; в обычной работе не нужен.
+// Пока идёт REINDEX, запись в базу заблокирована. В PostgreSQL выполняется только ANALYZE:
+// индексы там не устаревают от загрузки, а REINDEX блокирует таблицу.
+func (s ParcelStore) Reindex() (err error) {
+	span := s.startSpan("Reindex")
+	defer func() { endSpan(span, err) }()
+
+	if !s.life.enter() {
+		return ErrStoreClosed
+	}
+	defer s.life.leave()
+
+	if s.cfg.Dialect == DialectSQLite {
+		if _, err := s.conn.Exec("REINDEX"); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.conn.Exec("ANALYZE")
+	return err
+}
+
+// EstimatedStats быстро оценивает количество посылок и размер данных в байтах, не пересчитывая строки.
+// Значения приблизительные:
+//   - в SQLite количество — из статистики sqlite_stat1, которую собирает ANALYZE (см. Reindex), а пока её нет —
+//     COUNT(*) по parcel; удалённые мягко посылки тоже считаются. Размер — размер всего файла базы
+//     (page_count * page_size), включая остальные таблицы и индексы;
+//   - в PostgreSQL количество — reltuples из статистики планировщика (обновляется ANALYZE и autovacuum,
+//     до первого ANALYZE может быть -1 или 0), размер — pg_total_relation_size таблицы parcel с индексами.
+func (s ParcelStore) EstimatedStats() (rows int64, bytes int64, err error) {
+	span := s.startSpan("EstimatedStats")
+	defer func() { endSpan(span, err) }()
+
+	if s.cfg.Dialect == DialectPostgres {
+		err = s.read.QueryRow("SELECT reltuples::bigint, pg_total_relation_size(oid) FROM pg_class WHERE relname = 'parcel'").
+			Scan(&rows, &bytes)
+		return rows, bytes, err
+	}
+
+	if rows, err = s.statRows(); err != nil {
+		return 0, 0, err
+	}
+	err = s.read.QueryRow("SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&bytes)
+
+	return rows, bytes, err
+}
+
+// statRows возвращает количество строк parcel из sqlite_stat1 или, если ANALYZE ещё не собирал статистику, COUNT(*).
+// Первое число в sqlite_stat1.stat — количество строк таблицы (для индекса — проиндексированных строк).
+func (s ParcelStore) statRows() (rows int64, err error) {
+	var analyzed bool
+	err = s.read.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_stat1')").Scan(&analyzed)
+	if err != nil {
+		return 0, err
+	}
+
+	if analyzed {
+		var stat string
+		err = s.read.QueryRow("SELECT stat FROM sqlite_stat1 WHERE tbl = 'parcel' ORDER BY idx IS NOT NULL LIMIT 1").Scan(&stat)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+		if fields := strings.Fields(stat); len(fields) > 0 {
+			if rows, err = strconv.ParseInt(fields[0], 10, 64); err == nil {
+				return rows, nil
+			}
+		}
+	}
+
+	err = s.read.QueryRow("SELECT COUNT(*) FROM parcel").Scan(&rows)
+	return rows, err
+}
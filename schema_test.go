@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchemaVersion проверяет чтение версии схемы
+func TestSchemaVersion(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	fresh, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer fresh.Close()
+
+	// check
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion, version)
+
+	version, err = SchemaVersion(fresh)
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+
+	_, err = fresh.Exec("CREATE TABLE schema_migrations (version INTEGER NOT NULL PRIMARY KEY, applied_at TEXT NOT NULL)")
+	require.NoError(t, err)
+
+	version, err = SchemaVersion(fresh)
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+}
+
+// TestVacuum проверяет сжатие базы
+func TestVacuum(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// check
+	require.NoError(t, NewParcelStore(db).Vacuum())
+	require.NoError(t, NewParcelStoreWithConfig(db, StoreConfig{Dialect: DialectPostgres}).Vacuum())
+}
+
+// TestEstimatedStats проверяет приблизительную оценку размера таблицы
+func TestEstimatedStats(t *testing.T) {
+	// prepare
+	store := newTempStore(t)
+
+	// add
+	var last int64
+	for i := 0; i < 3; i++ {
+		id, err := store.Add(getTestParcel())
+		require.NoError(t, err)
+		last = id
+	}
+	// большой номер не должен раздувать оценку
+	require.NoError(t, store.ChangeNumber(last, 1_000_000_000))
+
+	// check
+	// до ANALYZE статистики нет, строки считаются
+	rows, bytes, err := store.EstimatedStats()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rows)
+	require.Positive(t, bytes)
+
+	// после ANALYZE оценка берётся из sqlite_stat1 и не пересчитывается до следующего
+	require.NoError(t, store.Reindex())
+	_, err = store.Add(getTestParcel())
+	require.NoError(t, err)
+	rows, _, err = store.EstimatedStats()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rows)
+}
+
+// TestReindex проверяет, что после Reindex у планировщика есть статистика
+func TestReindex(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// check
+	require.NoError(t, NewParcelStore(db).Reindex())
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'parcel'").Scan(&n))
+	require.Positive(t, n)
+}
+
+// newTempStore создаёт хранилище на новой временной БД со схемой InitSchema —
+// для тестов, которым мешают данные, оставшиеся в tracker.db от других тестов
+func newTempStore(t *testing.T, opts ...Option) *ParcelStore {
+	store, err := NewParcelStoreFromDSN("sqlite", filepath.Join(t.TempDir(), "tracker.db"), opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+// TestNewParcelStoreFromDSN проверяет создание схемы в новой базе и владение соединением
+func TestNewParcelStoreFromDSN(t *testing.T) {
+	// prepare
+	path := filepath.Join(t.TempDir(), "new.db")
+
+	store, err := NewParcelStoreFromDSN("sqlite", path)
+	require.NoError(t, err)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(id, ParcelStatusSent))
+
+	// check
+	detail, err := store.GetFull(id)
+	require.NoError(t, err)
+	require.Len(t, detail.History, 2)
+
+	version, err := SchemaVersion(store.conn)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion, version)
+
+	// повторная инициализация ничего не ломает
+	require.NoError(t, store.InitSchema())
+	require.NoError(t, store.Close())
+	require.Error(t, store.conn.Ping())
+
+	// хранилище из NewParcelStore базой не владеет
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, NewParcelStore(db).InitSchema())
+	require.NoError(t, NewParcelStore(db).Close())
+	require.NoError(t, db.Ping())
+}
@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// parcelSearchSchema полнотекстовый индекс FTS5 по адресу и комментарию посылки и триггеры,
+// которые держат его в соответствии с таблицей parcel. Индекс хранит только токены (content='parcel'),
+// сами значения читаются из parcel. Триггеры пересоздаются при каждом EnableFullTextSearch.
+const parcelSearchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS parcel_search USING fts5(address, note, content='parcel', content_rowid='number');
+
+DROP TRIGGER IF EXISTS parcel_search_insert;
+CREATE TRIGGER parcel_search_insert
+    AFTER INSERT
+    ON parcel
+BEGIN
+    INSERT INTO parcel_search (rowid, address, note) VALUES (NEW.number, NEW.address, NEW.note);
+END;
+
+DROP TRIGGER IF EXISTS parcel_search_delete;
+CREATE TRIGGER parcel_search_delete
+    AFTER DELETE
+    ON parcel
+BEGIN
+    INSERT INTO parcel_search (parcel_search, rowid, address, note) VALUES ('delete', OLD.number, OLD.address, OLD.note);
+END;
+
+DROP TRIGGER IF EXISTS parcel_search_update;
+CREATE TRIGGER parcel_search_update
+    AFTER UPDATE OF number, address, note
+    ON parcel
+BEGIN
+    INSERT INTO parcel_search (parcel_search, rowid, address, note) VALUES ('delete', OLD.number, OLD.address, OLD.note);
+    INSERT INTO parcel_search (rowid, address, note) VALUES (NEW.number, NEW.address, NEW.note);
+END;
+`
+
+// EnableFullTextSearch включает полнотекстовый поиск SearchFullText: создаёт индекс parcel_search
+// с триггерами и заполняет его по текущим посылкам. Повторный вызов безопасен.
+// Индекс строится на FTS5 SQLite, для других диалектов возвращается ErrUnsupportedDialect.
+func (s ParcelStore) EnableFullTextSearch() (err error) {
+	span := s.startSpan("EnableFullTextSearch")
+	defer func() { endSpan(span, err) }()
+
+	if s.cfg.Dialect != DialectSQLite {
+		return fmt.Errorf("full-text search: %w", ErrUnsupportedDialect)
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		if _, err := tx.db.Exec(parcelSearchSchema); err != nil {
+			return err
+		}
+		_, err := tx.db.Exec("INSERT INTO parcel_search (parcel_search) VALUES ('rebuild')")
+		return err
+	})
+}
+
+// SearchFullText возвращает посылки, адрес или комментарий которых подходят под запрос query
+// в синтаксисе FTS5 (например "ленина 5" или "курьер OR склад"), от более релевантных к менее.
+// Требует EnableFullTextSearch, для других диалектов возвращается ErrUnsupportedDialect.
+func (s ParcelStore) SearchFullText(query string) (res []Parcel, err error) {
+	span := s.startSpan("SearchFullText")
+	defer func() { endSpan(span, err) }()
+
+	if s.cfg.Dialect != DialectSQLite {
+		return nil, fmt.Errorf("full-text search: %w", ErrUnsupportedDialect)
+	}
+
+	return s.queryParcels("JOIN (SELECT rowid AS hit, rank FROM parcel_search WHERE parcel_search MATCH ?) AS fts ON fts.hit = parcel.number "+
+		"WHERE deleted_at IS NULL AND status <> ? ORDER BY fts.rank, number", query, ParcelStatusDraft)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSearchFullText проверяет полнотекстовый поиск по адресу и комментарию
+func TestSearchFullText(t *testing.T) {
+	// prepare
+	// EnableFullTextSearch меняет схему, поэтому не в общей tracker.db
+	store := newTempStore(t)
+	require.NoError(t, store.EnableFullTextSearch())
+	word := fmt.Sprintf("word%d", randRange.Intn(10_000_000))
+
+	// add
+	byAddress := getTestParcel()
+	byAddress.Address = "street " + word + " " + word
+	byAddressID, err := store.Add(byAddress)
+	require.NoError(t, err)
+
+	byNote := getTestParcel()
+	byNote.Note = "leave at the door, " + word
+	byNoteID, err := store.Add(byNote)
+	require.NoError(t, err)
+
+	changed, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	var numbers []int64
+	search := func(query string) []int64 {
+		parcels, err := store.SearchFullText(query)
+		require.NoError(t, err)
+		numbers = numbers[:0]
+		for _, p := range parcels {
+			numbers = append(numbers, p.Number)
+		}
+		return numbers
+	}
+
+	// в адресе слово встречается дважды, поэтому эта посылка выше
+	require.Equal(t, []int64{byAddressID, byNoteID}, search(word))
+
+	// индекс следует за изменениями адреса
+	require.NoError(t, store.SetAddress(changed, "avenue "+word+" "+word+" "+word))
+	require.Equal(t, []int64{changed, byAddressID, byNoteID}, search(word))
+
+	require.NoError(t, store.Delete(byNoteID))
+	require.Equal(t, []int64{changed, byAddressID}, search(word))
+
+	postgres := NewParcelStoreWithConfig(store.conn, StoreConfig{Dialect: DialectPostgres})
+	require.ErrorIs(t, postgres.EnableFullTextSearch(), ErrUnsupportedDialect)
+	_, err = postgres.SearchFullText(word)
+	require.ErrorIs(t, err, ErrUnsupportedDialect)
+}
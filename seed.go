@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+var (
+	seedStatuses = []string{ParcelStatusRegistered, ParcelStatusSent, ParcelStatusDelivered}
+	seedCities   = []string{"Псков", "Саратов", "Казань", "Тверь", "Омск"}
+	seedStreets  = []string{"ул. Колотушкина", "ул. Козлова", "пр. Мира", "ул. Садовая"}
+)
+
+// RandomParcel возвращает посылку со случайными клиентом, статусом и адресом
+func RandomParcel() Parcel {
+	return Parcel{
+		Client: rand.Int63n(10_000_000) + 1,
+		Status: seedStatuses[rand.Intn(len(seedStatuses))],
+		Address: fmt.Sprintf("%s, %s, д. %d",
+			seedCities[rand.Intn(len(seedCities))], seedStreets[rand.Intn(len(seedStreets))], rand.Intn(200)+1),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// SeedRandom добавляет в хранилище n случайных посылок (см. RandomParcel)
+// и возвращает их номера в порядке добавления
+func SeedRandom(store ParcelStore, n int) ([]int64, error) {
+	numbers := make([]int64, 0, n)
+	for i := 0; i < n; i++ {
+		number, err := store.Add(RandomParcel())
+		if err != nil {
+			return numbers, fmt.Errorf("seed parcel %d of %d: %w", i+1, n, err)
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrStoreClosed хранилище остановлено через Shutdown и новых операций не принимает
+var ErrStoreClosed = errors.New("parcel store is shut down")
+
+// lifecycle общий для всех копий ParcelStore учёт выполняющихся операций
+type lifecycle struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// enter регистрирует начало операции, false — хранилище уже останавливается.
+// Для хранилища, созданного не через конструктор (l == nil), учёта нет.
+func (l *lifecycle) enter() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.wg.Add(1)
+
+	return true
+}
+
+// leave отмечает завершение операции, начатой enter
+func (l *lifecycle) leave() {
+	if l != nil {
+		l.wg.Done()
+	}
+}
+
+// Shutdown останавливает хранилище: новые запросы и транзакции получают ErrStoreClosed,
+// уже начатые дорабатывают (транзакция WithTx — до фиксации или отката, чтение строк запроса — до конца
+// или закрытия строк), после чего база закрывается.
+// Если ctx истекает раньше, база закрывается сразу и возвращается ошибка ctx.
+// Закрывается и *sql.DB, переданный в конструктор (и StoreConfig.ReadReplica, если задана),
+// так что остальные их пользователи тоже остановятся.
+func (s ParcelStore) Shutdown(ctx context.Context) (err error) {
+	span := s.startSpan("Shutdown")
+	defer func() { endSpan(span, err) }()
+
+	if s.life != nil {
+		s.life.mu.Lock()
+		s.life.closed = true
+		s.life.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			s.life.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if s.cfg.ReadReplica != nil {
+		err = errors.Join(err, s.cfg.ReadReplica.Close())
+	}
+
+	return errors.Join(err, s.conn.Close())
+}
+
+// Close закрывает базу, если хранилище ею владеет (создано NewParcelStoreFromDSN),
+// дождавшись уже начатых операций, как Shutdown. Для хранилища из NewParcelStore
+// ничего не делает: базой владеет тот, кто её передал.
+func (s ParcelStore) Close() error {
+	if !s.owned {
+		return nil
+	}
+
+	return s.Shutdown(context.Background())
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestShutdown проверяет, что Shutdown дожидается начатой транзакции и отклоняет новые операции
+func TestShutdown(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+
+	store := NewParcelStore(db)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	txDone := make(chan error)
+	go func() {
+		txDone <- store.WithTx(func(tx ParcelStore) error {
+			close(started)
+			<-release
+			_, err := tx.Add(getTestParcel())
+			return err
+		})
+	}()
+	<-started
+
+	// shutdown
+	shutdownDone := make(chan error)
+	go func() {
+		shutdownDone <- store.Shutdown(context.Background())
+	}()
+
+	// check
+	require.Eventually(t, func() bool {
+		return store.WithTx(func(tx ParcelStore) error { return nil }) == ErrStoreClosed
+	}, time.Second, time.Millisecond)
+	_, err = store.GetByClientCode("any")
+	require.ErrorIs(t, err, ErrStoreClosed)
+
+	select {
+	case <-shutdownDone:
+		t.Fatal("Shutdown returned before the transaction finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-txDone)
+	require.NoError(t, <-shutdownDone)
+}
+
+// TestShutdownDeadline проверяет, что Shutdown не ждёт дольше срока контекста
+func TestShutdownDeadline(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+
+	store := NewParcelStore(db)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		_ = store.WithTx(func(tx ParcelStore) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// check
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, store.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+// TestShutdownWaitsForRows проверяет, что Shutdown дожидается закрытия строк начатого запроса
+func TestShutdownWaitsForRows(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+
+	store := NewParcelStore(db)
+	rows, err := store.db.Query("SELECT number FROM parcel")
+	require.NoError(t, err)
+
+	// shutdown
+	shutdownDone := make(chan error)
+	go func() {
+		shutdownDone <- store.Shutdown(context.Background())
+	}()
+
+	// check
+	select {
+	case <-shutdownDone:
+		t.Fatal("Shutdown returned before the rows were closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, rows.Close())
+	require.NoError(t, rows.Close())
+	require.NoError(t, <-shutdownDone)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrParcelDeleted возвращается GetStrict, если посылка была, но мягко удалена
+var ErrParcelDeleted = errors.New("parcel is deleted")
+
+// Мягко удалённые посылки (deleted_at не NULL, например discard после Merge)
+// обычные выборки не возвращают, для них есть отдельные методы ниже.
+
+// GetDeletedBetween возвращает мягко удалённые посылки, у которых DeletedAt
+// попадает в полуинтервал [from, to), в порядке удаления
+func (s ParcelStore) GetDeletedBetween(from, to time.Time) (res []Parcel, err error) {
+	span := s.startSpan("GetDeletedBetween")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels("WHERE deleted_at >= ? AND deleted_at < ? ORDER BY deleted_at, number",
+		formatTime(from), formatTime(to))
+}
+
+// GetStrict как Get, но отличает мягко удалённую посылку от отсутствующей:
+// для удалённой возвращает ErrParcelDeleted, для несуществующей — ErrParcelNotFound.
+func (s ParcelStore) GetStrict(number int64) (p Parcel, err error) {
+	span := s.startSpan("GetStrict", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	p, err = s.queryParcel("number = ?", number)
+	if err != nil {
+		return Parcel{}, err
+	}
+	if p.DeletedAt != "" {
+		return Parcel{}, ErrParcelDeleted
+	}
+
+	return p, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetDeletedBetween проверяет выборку мягко удалённых посылок за период
+func TestGetDeletedBetween(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	deletedAt := time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(randRange.Int63n(10_000_000)) * time.Second)
+	store := NewParcelStoreWithConfig(db, StoreConfig{Now: func() time.Time { return deletedAt }})
+
+	// add
+	keep, err := NewParcelStore(db).Add(getTestParcel())
+	require.NoError(t, err)
+	discard, err := NewParcelStore(db).Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.Merge(keep, discard))
+
+	// check
+	parcels, err := store.GetDeletedBetween(deletedAt, deletedAt.Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, parcels, 1)
+	require.Equal(t, discard, parcels[0].Number)
+	require.Equal(t, formatTime(deletedAt), parcels[0].DeletedAt)
+
+	parcels, err = store.GetDeletedBetween(deletedAt.Add(time.Second), deletedAt.Add(time.Hour))
+	require.NoError(t, err)
+	for _, p := range parcels {
+		require.NotEqual(t, discard, p.Number)
+	}
+
+	_, err = store.Get(discard)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+}
+
+// TestGetStrict проверяет различение удалённой и отсутствующей посылки
+func TestGetStrict(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	keep, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	discard, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.Merge(keep, discard))
+
+	missing, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(missing))
+
+	// check
+	p, err := store.GetStrict(keep)
+	require.NoError(t, err)
+	require.Equal(t, keep, p.Number)
+
+	_, err = store.GetStrict(discard)
+	require.ErrorIs(t, err, ErrParcelDeleted)
+	_, err = store.Get(discard)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	_, err = store.GetStrict(missing)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// logQuery пишет запрос в StoreConfig.SQLLogger на уровне Debug, если логгер задан
+func (q querier) logQuery(query string, args []any) {
+	if q.log == nil || !q.log.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	q.log.Debug("sql", "query", query, "args", redactArgs(args))
+}
+
+// redactArgs готовит аргументы запроса для лога. Строки могут содержать
+// персональные данные (адрес, комментарий, внешние номера), поэтому вместо них
+// пишется только длина. Открыто остаются числа и известные статусы посылок.
+func redactArgs(args []any) []string {
+	res := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			if knownStatuses[v] {
+				res[i] = fmt.Sprintf("%q", v)
+			} else {
+				res[i] = fmt.Sprintf("<redacted string, %d bytes>", len(v))
+			}
+		case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			res[i] = fmt.Sprint(v)
+		default:
+			res[i] = fmt.Sprintf("<redacted %T>", v)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSQLLogger проверяет, что запросы логируются без строковых аргументов
+func TestSQLLogger(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	store := NewParcelStoreWithConfig(db, StoreConfig{SQLLogger: logger})
+
+	// check
+	_, err = store.GetByExternalRef("Москва, ул. Тверская, 1")
+	require.ErrorIs(t, err, ErrParcelNotFound)
+	require.Contains(t, buf.String(), "WHERE external_ref = ?")
+	require.Contains(t, buf.String(), "redacted string")
+	require.NotContains(t, buf.String(), "Тверская")
+
+	buf.Reset()
+	_, err = store.GetStuck(ParcelStatusSent, time.Hour)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), ParcelStatusSent)
+
+	// без логгера и на уровне выше Debug ничего не пишется
+	buf.Reset()
+	quiet := slog.New(slog.NewTextHandler(&buf, nil))
+	_, err = NewParcelStoreWithConfig(db, StoreConfig{SQLLogger: quiet}).GetStuck(ParcelStatusSent, time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, buf.String())
+}
+
+// TestRedactArgs проверяет маскирование аргументов запроса
+func TestRedactArgs(t *testing.T) {
+	require.Equal(t,
+		[]string{"42", `"delivered"`, "<redacted string, 4 bytes>", "<nil>", "<redacted []uint8>"},
+		redactArgs([]any{42, ParcelStatusDelivered, "test", nil, []byte("x")}))
+}
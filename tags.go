@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrEmptyTag метка посылки пустая
+var ErrEmptyTag = errors.New("tag is empty")
+
+// AddTag добавляет посылке метку, повторное добавление той же метки ничего не меняет
+func (s ParcelStore) AddTag(number int64, tag string) (err error) {
+	span := s.startSpan("AddTag", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return ErrEmptyTag
+	}
+
+	return s.WithTx(func(tx ParcelStore) error {
+		if _, err := tx.queryParcel("number = ? AND deleted_at IS NULL", number); err != nil {
+			return err
+		}
+
+		_, err := tx.db.Exec("INSERT INTO parcel_tag (number, tag) VALUES (?, ?) ON CONFLICT DO NOTHING", number, tag)
+		return err
+	})
+}
+
+// AddTagMany добавляет метку tag всем посылкам numbers в одной транзакции и возвращает,
+// сколько меток добавлено заново; уже помеченные посылки пропускаются.
+// Если какой-то посылки нет, метка не добавляется ни одной, а ошибка содержит *ParcelError с её номером.
+// Ограничение размера пачки такое же, как у AddBatch, с ChunkBatches пачка делится на запросы
+// внутри той же транзакции.
+func (s ParcelStore) AddTagMany(numbers []int64, tag string) (added int, err error) {
+	span := s.startSpan("AddTagMany", attribute.Int("parcel.count", len(numbers)))
+	defer func() { endSpan(span, err) }()
+
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return 0, ErrEmptyTag
+	}
+	if len(numbers) == 0 {
+		return 0, nil
+	}
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		added = 0
+		return tx.forEachBatch(len(numbers), func(start, end int) error {
+			n, err := tx.addTagBatch(numbers[start:end], start, tag)
+			added += n
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return added, nil
+}
+
+// addTagBatch помечает посылки numbers меткой tag, offset — индекс numbers[0] в исходном срезе
+func (s ParcelStore) addTagBatch(numbers []int64, offset int, tag string) (int, error) {
+	args := make([]any, 0, len(numbers)+1)
+	args = append(args, tag)
+	for _, number := range numbers {
+		args = append(args, number)
+	}
+
+	found := make(map[int64]bool, len(numbers))
+	rows, err := s.db.Query("SELECT number FROM parcel WHERE deleted_at IS NULL AND number IN ("+placeholders(len(numbers))+")", args[1:]...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var number int64
+		if err := rows.Scan(&number); err != nil {
+			return 0, err
+		}
+		found[number] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, number := range numbers {
+		if !found[number] {
+			return 0, &ParcelError{Index: offset + i, Number: number, Op: "add tag", Err: ErrParcelNotFound}
+		}
+	}
+
+	res, err := s.db.Exec("INSERT INTO parcel_tag (number, tag) SELECT number, ? FROM parcel WHERE deleted_at IS NULL AND number IN ("+
+		placeholders(len(numbers))+") ON CONFLICT DO NOTHING", args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// RemoveTag снимает метку с посылки
+func (s ParcelStore) RemoveTag(number int64, tag string) (err error) {
+	span := s.startSpan("RemoveTag", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.db.Exec("DELETE FROM parcel_tag WHERE number = ? AND tag = ?", number, strings.TrimSpace(tag))
+	return err
+}
+
+// GetTags возвращает метки посылки по алфавиту
+func (s ParcelStore) GetTags(number int64) (tags []string, err error) {
+	span := s.startSpan("GetTags", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.read.Query("SELECT tag FROM parcel_tag WHERE number = ? ORDER BY tag", number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
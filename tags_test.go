@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestTags проверяет добавление и снятие меток посылки
+func TestTags(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddTag(id, "urgent"))
+	require.NoError(t, store.AddTag(id, " fragile "))
+	require.NoError(t, store.AddTag(id, "urgent"))
+
+	// check
+	tags, err := store.GetTags(id)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fragile", "urgent"}, tags)
+
+	require.NoError(t, store.RemoveTag(id, "urgent"))
+	tags, err = store.GetTags(id)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fragile"}, tags)
+
+	require.ErrorIs(t, store.AddTag(id, " "), ErrEmptyTag)
+	require.ErrorIs(t, store.AddTag(-1, "urgent"), ErrParcelNotFound)
+}
+
+// TestAddTagMany проверяет добавление метки сразу нескольким посылкам
+func TestAddTagMany(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	var numbers []int64
+	for i := 0; i < 3; i++ {
+		id, err := store.Add(getTestParcel())
+		require.NoError(t, err)
+		numbers = append(numbers, id)
+	}
+	require.NoError(t, store.AddTag(numbers[0], "batch-2024-06"))
+
+	// check
+	_, err = store.AddTagMany(numbers, " ")
+	require.ErrorIs(t, err, ErrEmptyTag)
+
+	added, err := store.AddTagMany(append(numbers, numbers[1]), "batch-2024-06")
+	require.NoError(t, err)
+	require.Equal(t, 2, added)
+	for _, number := range numbers {
+		tags, err := store.GetTags(number)
+		require.NoError(t, err)
+		require.Equal(t, []string{"batch-2024-06"}, tags)
+	}
+
+	added, err = store.AddTagMany(numbers, "batch-2024-06")
+	require.NoError(t, err)
+	require.Equal(t, 0, added)
+
+	// несуществующая посылка откатывает всю пачку
+	_, err = store.AddTagMany([]int64{numbers[0], -1}, "urgent")
+	require.ErrorIs(t, err, ErrParcelNotFound)
+	var perr *ParcelError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, 1, perr.Index)
+	tags, err := store.GetTags(numbers[0])
+	require.NoError(t, err)
+	require.Equal(t, []string{"batch-2024-06"}, tags)
+}
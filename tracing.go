@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName имя инструментирующей библиотеки в спанах
+	tracerName = "github.com/Yandex-Practicum/go-db-sql-final"
+
+	attrParcelNumber = "parcel.number"
+	attrParcelClient = "parcel.client"
+)
+
+// tracer берёт провайдер из otel.GetTracerProvider().
+// Пока провайдер не задан через otel.SetTracerProvider, спаны ничего не делают.
+var tracer = otel.Tracer(tracerName)
+
+// startSpan открывает спан "parcelstore.<op>" дочерним к спану из контекста, переданного в WithContext.
+// Без WithContext спан корневой.
+func (s ParcelStore) startSpan(op string, attrs ...attribute.KeyValue) trace.Span {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return startSpanContext(ctx, op, attrs...)
+}
+
+// startSpanContext открывает спан "parcelstore.<op>" дочерним к спану из ctx
+func startSpanContext(ctx context.Context, op string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := tracer.Start(ctx, "parcelstore."+op, trace.WithAttributes(attrs...))
+	return span
+}
+
+// endSpan закрывает спан и при ошибке помечает его статусом Error
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestStartSpanParent проверяет, что спаны хранилища открываются дочерними к спану из WithContext
+func TestStartSpanParent(t *testing.T) {
+	// prepare
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), parent)
+	store := ParcelStore{}
+
+	// check
+	// без WithContext спан корневой
+	span := store.startSpan("Get")
+	require.False(t, span.SpanContext().TraceID().IsValid())
+	endSpan(span, nil)
+
+	// без заданного провайдера спан пустой, но наследует трассу родителя
+	span = store.WithContext(ctx).startSpan("Get")
+	require.Equal(t, parent.TraceID(), span.SpanContext().TraceID())
+	endSpan(span, nil)
+}
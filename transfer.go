@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrInvalidClient номер клиента не положительный или клиенты совпадают
+var ErrInvalidClient = errors.New("invalid client")
+
+// mutableStatuses статусы, в которых данные посылки ещё можно менять
+var mutableStatuses = []string{ParcelStatusDraft, ParcelStatusRegistered}
+
+// TransferParcels переносит все посылки клиента fromClient клиенту toClient одной транзакцией,
+// например при объединении учётных записей. Возвращает количество перенесённых посылок.
+func (s ParcelStore) TransferParcels(fromClient, toClient int64) (n int, err error) {
+	span := s.startSpan("TransferParcels", attribute.Int64(attrParcelClient, fromClient), attribute.Int64("parcel.to_client", toClient))
+	defer func() { endSpan(span, err) }()
+
+	return s.transferParcels(fromClient, toClient, nil)
+}
+
+// TransferMutableParcels как TransferParcels, но переносит только посылки,
+// которые ещё не отправлены (черновики и зарегистрированные)
+func (s ParcelStore) TransferMutableParcels(fromClient, toClient int64) (n int, err error) {
+	span := s.startSpan("TransferMutableParcels", attribute.Int64(attrParcelClient, fromClient), attribute.Int64("parcel.to_client", toClient))
+	defer func() { endSpan(span, err) }()
+
+	return s.transferParcels(fromClient, toClient, mutableStatuses)
+}
+
+// transferParcels переносит посылки клиента, если statuses не пустой — только в этих статусах
+func (s ParcelStore) transferParcels(fromClient, toClient int64, statuses []string) (n int, err error) {
+	if fromClient <= 0 || toClient <= 0 || fromClient == toClient {
+		return 0, ErrInvalidClient
+	}
+
+	where := "WHERE client = ? AND deleted_at IS NULL"
+	args := []any{fromClient}
+	if len(statuses) > 0 {
+		where += " AND status IN (" + placeholders(len(statuses)) + ")"
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+
+	err = s.trackMany("transfer", where, args, func(tx ParcelStore) error {
+		res, err := tx.db.Exec("UPDATE parcel SET client = ? "+where, append([]any{toClient}, args...)...)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		n = int(affected)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransferParcels проверяет перенос посылок между клиентами
+func TestTransferParcels(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	from := randRange.Int63n(10_000_000) + 1
+	to := from + 10_000_000
+
+	// add
+	parcel := getTestParcel()
+	parcel.Client = from
+	registered, err := store.Add(parcel)
+	require.NoError(t, err)
+	sent, err := store.Add(parcel)
+	require.NoError(t, err)
+	require.NoError(t, store.SetStatus(sent, ParcelStatusSent))
+
+	// check
+	n, err := store.TransferMutableParcels(from, to)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	p, err := store.Get(registered)
+	require.NoError(t, err)
+	require.Equal(t, to, p.Client)
+	p, err = store.Get(sent)
+	require.NoError(t, err)
+	require.Equal(t, from, p.Client)
+
+	n, err = store.TransferParcels(from, to)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	parcels, err := store.GetByClient(from)
+	require.NoError(t, err)
+	require.Empty(t, parcels)
+	parcels, err = store.GetByClient(to)
+	require.NoError(t, err)
+	require.Len(t, parcels, 2)
+
+	_, err = store.TransferParcels(to, to)
+	require.ErrorIs(t, err, ErrInvalidClient)
+	_, err = store.TransferParcels(0, to)
+	require.ErrorIs(t, err, ErrInvalidClient)
+}
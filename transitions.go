@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrInvalidTransition переход между статусами не разрешён statusTransitions
+var ErrInvalidTransition = errors.New("status transition is not allowed")
+
+// statusTransitions допустимые переходы между статусами посылки
+var statusTransitions = map[string][]string{
+	ParcelStatusDraft:      {ParcelStatusRegistered},
+	ParcelStatusRegistered: {ParcelStatusSent, ParcelStatusProcessing},
+	ParcelStatusProcessing: {ParcelStatusSent},
+	ParcelStatusSent:       {ParcelStatusDelivered, ParcelStatusLost, ParcelStatusReturned},
+}
+
+// knownStatuses все статусы, которые может иметь посылка
+var knownStatuses = map[string]bool{
+	ParcelStatusDraft:      true,
+	ParcelStatusRegistered: true,
+	ParcelStatusProcessing: true,
+	ParcelStatusSent:       true,
+	ParcelStatusDelivered:  true,
+	ParcelStatusLost:       true,
+	ParcelStatusReturned:   true,
+}
+
+// CanTransition сообщает, разрешён ли переход из статуса from в статус to
+func CanTransition(from, to string) bool {
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTerminal сообщает, что из статуса нет переходов: посылка закончила путь
+func isTerminal(status string) bool {
+	return knownStatuses[status] && len(statusTransitions[status]) == 0
+}
+
+// terminalStatuses все конечные статусы, см. isTerminal
+func terminalStatuses() []any {
+	var res []any
+	for status := range knownStatuses {
+		if isTerminal(status) {
+			res = append(res, status)
+		}
+	}
+
+	return res
+}
+
+// CompareAndSetStatus переводит посылку в статус status, только если её текущий статус — expected,
+// одним UPDATE без предварительного чтения, и сообщает, применилось ли изменение.
+// Переход expected -> status должен быть разрешён statusTransitions. Если посылки нет,
+// возвращает ErrParcelNotFound, если она задержана Hold — ErrParcelOnHold.
+func (s ParcelStore) CompareAndSetStatus(number int64, expected, status string) (applied bool, err error) {
+	span := s.startSpan("CompareAndSetStatus", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("set status", number, func(tx ParcelStore) (int64, error) {
+			applied, err = tx.CompareAndSetStatus(number, expected, status)
+			// неприменённое изменение в журнал и outbox не попадает
+			if !applied {
+				return 0, err
+			}
+			return number, err
+		})
+		return applied, err
+	}
+
+	if !CanTransition(expected, status) {
+		return false, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, expected, status)
+	}
+	if status == ParcelStatusDelivered && s.cfg.RequireSignature {
+		return false, ErrSignatureRequired
+	}
+
+	err = s.WithTx(func(tx ParcelStore) error {
+		res, err := tx.db.Exec("UPDATE parcel SET status = ? WHERE number = ? AND status = ? AND on_hold = 0 AND deleted_at IS NULL",
+			status, number, expected)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			// статус не совпал или посылку нельзя менять — уточняем, что именно
+			p, err := tx.queryParcel("number = ? AND deleted_at IS NULL", number)
+			if err != nil {
+				return err
+			}
+			if p.OnHold {
+				return ErrParcelOnHold
+			}
+			return nil
+		}
+
+		applied = true
+		return tx.fillDefaultAddress(number, status)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+// GetEligibleForAutoAdvance возвращает посылки, которые планировщик может сам перевести в sent:
+// зарегистрированные, с назначенной курьерской службой, заполненным адресом (не заглушкой)
+// и не задержанные Hold. Сначала идут более срочные, при равном Priority — созданные раньше.
+func (s ParcelStore) GetEligibleForAutoAdvance() (res []Parcel, err error) {
+	span := s.startSpan("GetEligibleForAutoAdvance")
+	defer func() { endSpan(span, err) }()
+
+	return s.queryParcels(`WHERE status = ? AND carrier IS NOT NULL AND carrier <> ''
+		AND address <> '' AND address <> ? AND on_hold = 0 AND deleted_at IS NULL
+		ORDER BY priority DESC, created_at, number`,
+		ParcelStatusRegistered, s.cfg.AddressPlaceholder)
+}
+
+// AdvanceDuePickups переводит в статус sent все зарегистрированные посылки,
+// время забора которых (ScheduledAt) уже наступило к моменту now. Задержанные Hold посылки пропускаются.
+// Обновление выполняется одной транзакцией, история статусов пишется триггером,
+// журнал аудита — по записи на каждую посылку. Возвращает количество переведённых посылок.
+func (s ParcelStore) AdvanceDuePickups(now time.Time) (n int, err error) {
+	span := s.startSpan("AdvanceDuePickups")
+	defer func() { endSpan(span, err) }()
+
+	if !CanTransition(ParcelStatusRegistered, ParcelStatusSent) {
+		return 0, nil
+	}
+
+	where := "WHERE status = ? AND scheduled_at IS NOT NULL AND scheduled_at <= ? AND on_hold = 0 AND deleted_at IS NULL"
+	args := []any{ParcelStatusRegistered, formatTime(now)}
+
+	err = s.trackMany("advance pickup", where, args, func(tx ParcelStore) error {
+		res, err := tx.db.Exec("UPDATE parcel SET status = ? "+where, append([]any{ParcelStatusSent}, args...)...)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		n = int(affected)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// FindInvalidStatuses возвращает посылки, статус которых не входит в knownStatuses,
+// например оставшиеся после неудачной миграции
+func (s ParcelStore) FindInvalidStatuses() (res []Parcel, err error) {
+	span := s.startSpan("FindInvalidStatuses")
+	defer func() { endSpan(span, err) }()
+
+	statuses := make([]string, 0, len(knownStatuses))
+	for status := range knownStatuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	args := make([]any, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+
+	return s.queryParcels("WHERE status NOT IN ("+placeholders(len(args))+") AND deleted_at IS NULL ORDER BY number", args...)
+}
+
+// RepairStatus принудительно задаёт посылке статус в обход statusTransitions,
+// например чтобы исправить статус, найденный FindInvalidStatuses.
+// Новый статус должен быть известным, смена попадает в историю статусов.
+// Задержанную Hold посылку исправить нельзя: ErrParcelOnHold.
+func (s ParcelStore) RepairStatus(number int64, status string) (err error) {
+	span := s.startSpan("RepairStatus", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tracksChanges() {
+		_, err = s.audited("repair status", number, func(tx ParcelStore) (int64, error) {
+			return number, tx.RepairStatus(number, status)
+		})
+		return err
+	}
+
+	if !knownStatuses[status] {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, status)
+	}
+
+	res, err := s.db.Exec("UPDATE parcel SET status = ? WHERE number = ? AND on_hold = 0 AND deleted_at IS NULL", status, number)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		if err := s.checkNotOnHold(number); err != nil {
+			return err
+		}
+		return ErrParcelNotFound
+	}
+
+	return nil
+}
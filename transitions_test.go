@@ -0,0 +1,241 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestAdvanceDuePickups проверяет перевод в sent посылок с наступившим временем забора
+func TestAdvanceDuePickups(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	now := time.Now()
+
+	// add
+	due := getTestParcel()
+	due.ScheduledAt = formatTime(now.Add(-time.Hour))
+	dueID, err := store.Add(due)
+	require.NoError(t, err)
+
+	later := getTestParcel()
+	later.ScheduledAt = formatTime(now.Add(time.Hour))
+	laterID, err := store.Add(later)
+	require.NoError(t, err)
+
+	unscheduledID, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// advance
+	n, err := store.AdvanceDuePickups(now)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 1)
+
+	// check
+	for number, status := range map[int64]string{
+		dueID:         ParcelStatusSent,
+		laterID:       ParcelStatusRegistered,
+		unscheduledID: ParcelStatusRegistered,
+	} {
+		p, err := store.Get(number)
+		require.NoError(t, err)
+		require.Equal(t, status, p.Status)
+	}
+}
+
+// TestRepairStatus проверяет поиск и исправление неизвестных статусов
+func TestRepairStatus(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE parcel SET status = 'SENT ' WHERE number = ?", id)
+	require.NoError(t, err)
+
+	// check
+	invalid, err := store.FindInvalidStatuses()
+	require.NoError(t, err)
+	found := false
+	for _, p := range invalid {
+		if p.Number == id {
+			found = true
+			require.Equal(t, "SENT ", p.Status)
+		}
+	}
+	require.True(t, found)
+
+	require.ErrorIs(t, store.RepairStatus(id, "sent?"), ErrUnknownStatus)
+	require.NoError(t, store.RepairStatus(id, ParcelStatusSent))
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusSent, stored.Status)
+
+	invalid, err = store.FindInvalidStatuses()
+	require.NoError(t, err)
+	for _, p := range invalid {
+		require.NotEqual(t, id, p.Number)
+	}
+
+	require.ErrorIs(t, store.RepairStatus(-1, ParcelStatusSent), ErrParcelNotFound)
+}
+
+// TestGetEligibleForAutoAdvance проверяет отбор посылок для автоматического перевода в sent
+func TestGetEligibleForAutoAdvance(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	add := func() int64 {
+		id, err := store.Add(getTestParcel())
+		require.NoError(t, err)
+		return id
+	}
+	eligible := add()
+	require.NoError(t, store.SetCarrier(eligible, "courier"))
+	noCarrier := add()
+	held := add()
+	require.NoError(t, store.SetCarrier(held, "courier"))
+	require.NoError(t, store.Hold(held, "legal"))
+	sent := add()
+	require.NoError(t, store.SetCarrier(sent, "courier"))
+	require.NoError(t, store.SetStatus(sent, ParcelStatusSent))
+
+	// check
+	parcels, err := store.GetEligibleForAutoAdvance()
+	require.NoError(t, err)
+
+	numbers := make(map[int64]bool)
+	for _, p := range parcels {
+		require.Equal(t, ParcelStatusRegistered, p.Status)
+		require.NotEmpty(t, p.Carrier)
+		require.False(t, p.OnHold)
+		numbers[p.Number] = true
+	}
+	require.True(t, numbers[eligible])
+	require.False(t, numbers[noCarrier])
+	require.False(t, numbers[held])
+	require.False(t, numbers[sent])
+}
+
+// FuzzSetStatus проверяет на случайных парах статусов, что CanTransition разрешает только переходы
+// из statusTransitions. Проверка не обращается к БД, поэтому её можно гонять с -fuzz.
+func FuzzSetStatus(f *testing.F) {
+	// prepare
+	for from := range knownStatuses {
+		for to := range knownStatuses {
+			f.Add(from, to)
+		}
+	}
+	f.Add("", "")
+	f.Add("unknown", ParcelStatusSent)
+	f.Add(ParcelStatusRegistered, "SENT")
+
+	// check
+	f.Fuzz(func(t *testing.T, from, to string) {
+		allowed := CanTransition(from, to)
+
+		inRegistry := false
+		for _, next := range statusTransitions[from] {
+			inRegistry = inRegistry || next == to
+		}
+		require.Equal(t, inRegistry, allowed)
+		if allowed {
+			require.True(t, knownStatuses[from])
+			require.True(t, knownStatuses[to])
+			require.False(t, isTerminal(from))
+			require.NotEqual(t, from, to)
+		}
+	})
+}
+
+// TestRepairStatusBypass проверяет, что RepairStatus задаёт любой известный статус в обход statusTransitions
+func TestRepairStatusBypass(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	// переходы из delivered и lost statusTransitions запрещает, RepairStatus — нет
+	for _, status := range []string{ParcelStatusDelivered, ParcelStatusRegistered, ParcelStatusLost, ParcelStatusSent} {
+		require.NoError(t, store.RepairStatus(id, status))
+
+		stored, err := store.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, status, stored.Status)
+	}
+
+	for _, status := range []string{"SENT", ""} {
+		require.ErrorIs(t, store.RepairStatus(id, status), ErrUnknownStatus)
+
+		stored, err := store.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, ParcelStatusSent, stored.Status)
+	}
+}
+
+// TestCompareAndSetStatus проверяет смену статуса только при совпадении текущего
+func TestCompareAndSetStatus(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db, WithAudit())
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	applied, err := store.CompareAndSetStatus(id, ParcelStatusRegistered, ParcelStatusProcessing)
+	require.NoError(t, err)
+	require.True(t, applied)
+
+	// второй обработчик с устаревшим ожиданием ничего не меняет
+	applied, err = store.CompareAndSetStatus(id, ParcelStatusRegistered, ParcelStatusSent)
+	require.NoError(t, err)
+	require.False(t, applied)
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, ParcelStatusProcessing, stored.Status)
+
+	// в журнале только применённое изменение
+	trail, err := store.GetAuditTrail(id)
+	require.NoError(t, err)
+	require.Len(t, trail, 2)
+	require.Equal(t, "set status", trail[1].Operation)
+
+	_, err = store.CompareAndSetStatus(id, ParcelStatusProcessing, ParcelStatusDelivered)
+	require.ErrorIs(t, err, ErrInvalidTransition)
+	_, err = store.CompareAndSetStatus(-1, ParcelStatusRegistered, ParcelStatusSent)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	require.NoError(t, store.Hold(id, "legal"))
+	_, err = store.CompareAndSetStatus(id, ParcelStatusProcessing, ParcelStatusSent)
+	require.ErrorIs(t, err, ErrParcelOnHold)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNoTx возвращается методами, которые можно вызывать только внутри WithTx
+var ErrNoTx = errors.New("operation requires a transaction, use WithTx")
+
+// dbtx общие методы *sql.DB и *sql.Tx
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// querier выполняет запросы хранилища через *sql.DB или *sql.Tx,
+// переводя плейсхолдеры в синтаксис диалекта и при необходимости логируя запросы.
+// Вне транзакции запросы учитываются в life для Shutdown, внутри WithTx life == nil:
+// транзакцию целиком учитывает WithTx.
+type querier struct {
+	q       dbtx
+	dialect Dialect
+	log     *slog.Logger
+	life    *lifecycle
+}
+
+func (q querier) Exec(query string, args ...any) (sql.Result, error) {
+	if !q.life.enter() {
+		return nil, ErrStoreClosed
+	}
+	defer q.life.leave()
+
+	query = q.dialect.rebind(query)
+	q.logQuery(query, args)
+	return q.q.Exec(query, args...)
+}
+
+// Query учитывает запрос в life до тех пор, пока строки не прочитаны до конца или не закрыты,
+// чтобы Shutdown не закрыл базу посреди чтения
+func (q querier) Query(query string, args ...any) (*rows, error) {
+	if !q.life.enter() {
+		return nil, ErrStoreClosed
+	}
+	leave := sync.OnceFunc(q.life.leave)
+
+	query = q.dialect.rebind(query)
+	q.logQuery(query, args)
+	r, err := q.q.Query(query, args...)
+	if err != nil {
+		leave()
+		return nil, err
+	}
+
+	return &rows{Rows: r, leave: leave}, nil
+}
+
+// rows строки querier.Query, которые отмечают конец запроса в lifecycle
+type rows struct {
+	*sql.Rows
+	leave func()
+}
+
+// Next при исчерпании строк, как и Close, завершает запрос: database/sql сам закрывает их в этот момент
+func (r *rows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.leave()
+	return false
+}
+
+// Close закрывает строки и завершает запрос, повторный вызов ничего не учитывает
+func (r *rows) Close() error {
+	err := r.Rows.Close()
+	r.leave()
+	return err
+}
+
+// QueryRow после Shutdown не отклоняется (*sql.Row нельзя вернуть с ошибкой),
+// но после закрытия базы его Scan вернёт ошибку database/sql.
+// Запрос учитывается в life только до возврата *sql.Row: Shutdown не дожидается его Scan
+func (q querier) QueryRow(query string, args ...any) *sql.Row {
+	if q.life.enter() {
+		defer q.life.leave()
+	}
+
+	query = q.dialect.rebind(query)
+	q.logQuery(query, args)
+	return q.q.QueryRow(query, args...)
+}
+
+// WithTx выполняет fn в одной транзакции: все методы хранилища tx работают через неё.
+// Если fn возвращает ошибку или паникует, транзакция откатывается, иначе фиксируется.
+// При временной ошибке (StoreConfig.IsRetryable) транзакция повторяется целиком,
+// так что fn может быть вызвана несколько раз.
+// Вложенный вызов WithTx переиспользует уже открытую транзакцию и не повторяется сам.
+func (s ParcelStore) WithTx(fn func(tx ParcelStore) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	if !s.life.enter() {
+		return ErrStoreClosed
+	}
+	defer s.life.leave()
+
+	return s.retry(func() error {
+		return s.runTx(fn)
+	})
+}
+
+// runTx выполняет одну попытку транзакции WithTx
+func (s ParcelStore) runTx(fn func(tx ParcelStore) error) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	// после Commit откат ничего не делает
+	defer tx.Rollback()
+
+	txStore := s
+	txStore.tx = tx
+	txStore.db = querier{q: tx, dialect: s.cfg.Dialect, log: s.cfg.SQLLogger}
+	// внутри транзакции и чтение идёт через неё, реплика не используется
+	txStore.read = txStore.db
+
+	if err := fn(txStore); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// primary возвращает копию хранилища, которая и читает из основной базы,
+// для чтения перед записью вне транзакции
+func (s ParcelStore) primary() ParcelStore {
+	s.read = s.db
+	return s
+}
+
+// GetForUpdate читает посылку и блокирует её от изменения другими транзакциями
+// до конца текущей. Вызывается только внутри WithTx, иначе возвращает ErrNoTx.
+//
+// В PostgreSQL используется SELECT ... FOR UPDATE. В SQLite блокировок строк нет:
+// писать в базу одновременно может только одна транзакция, а конкурирующая получит
+// SQLITE_BUSY при первой записи. Чтобы вторая транзакция ждала с самого начала,
+// открывайте базу с _txlock=immediate и _pragma=busy_timeout(...) — тогда уже BEGIN
+// берёт блокировку на запись, и чтение в GetForUpdate видит актуальные данные.
+func (s ParcelStore) GetForUpdate(number int64) (p Parcel, err error) {
+	span := s.startSpan("GetForUpdate", attribute.Int64(attrParcelNumber, number))
+	defer func() { endSpan(span, err) }()
+
+	if s.tx == nil {
+		return Parcel{}, ErrNoTx
+	}
+
+	where := "number = ? AND deleted_at IS NULL"
+	if s.cfg.Dialect == DialectPostgres {
+		where += " FOR UPDATE"
+	}
+
+	return s.queryParcel(where, number)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithTx проверяет фиксацию и откат транзакции
+func TestWithTx(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	errRollback := errors.New("rollback")
+
+	// commit
+	var committed int64
+	err = store.WithTx(func(tx ParcelStore) error {
+		committed, err = tx.Add(getTestParcel())
+		return err
+	})
+	require.NoError(t, err)
+	_, err = store.Get(committed)
+	require.NoError(t, err)
+
+	// rollback
+	var rolledBack int64
+	err = store.WithTx(func(tx ParcelStore) error {
+		rolledBack, err = tx.Add(getTestParcel())
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	_, err = store.Get(rolledBack)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+}
+
+// TestGetForUpdate проверяет чтение посылки с блокировкой внутри транзакции
+func TestGetForUpdate(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// вне транзакции
+	_, err = store.GetForUpdate(id)
+	require.ErrorIs(t, err, ErrNoTx)
+
+	// внутри транзакции
+	err = store.WithTx(func(tx ParcelStore) error {
+		p, err := tx.GetForUpdate(id)
+		if err != nil {
+			return err
+		}
+		require.Equal(t, id, p.Number)
+
+		return tx.SetStatus(id, ParcelStatusSent)
+	})
+	require.NoError(t, err)
+}
+
+// TestWithTxRetry проверяет повтор транзакции после временной ошибки
+func TestWithTxRetry(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	errTransient := errors.New("transient")
+	store := NewParcelStoreWithConfig(db, StoreConfig{
+		IsRetryable: func(err error) bool { return errors.Is(err, errTransient) },
+		MaxRetries:  2,
+		RetryDelay:  time.Millisecond,
+	})
+
+	// повтор до успеха
+	calls := 0
+	err = store.WithTx(func(tx ParcelStore) error {
+		calls++
+		if calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	// повторы исчерпаны
+	calls = 0
+	err = store.WithTx(func(tx ParcelStore) error {
+		calls++
+		return errTransient
+	})
+	require.ErrorIs(t, err, errTransient)
+	require.Equal(t, 3, calls)
+
+	// постоянная ошибка не повторяется
+	calls = 0
+	err = store.WithTx(func(tx ParcelStore) error {
+		calls++
+		return sql.ErrNoRows
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Equal(t, 1, calls)
+}
+
+// pgError имитирует ошибку драйвера PostgreSQL с кодом SQLSTATE
+type pgError string
+
+func (e pgError) Error() string    { return "pg: " + string(e) }
+func (e pgError) SQLState() string { return string(e) }
+
+// TestIsRetryablePostgres проверяет классификацию ошибок PostgreSQL
+func TestIsRetryablePostgres(t *testing.T) {
+	require.True(t, IsRetryablePostgres(fmt.Errorf("commit: %w", pgError("40001"))))
+	require.True(t, IsRetryablePostgres(pgError("40P01")))
+	require.True(t, IsRetryablePostgres(syscall.ECONNRESET))
+	require.False(t, IsRetryablePostgres(pgError("23505")))
+	require.False(t, IsRetryablePostgres(errors.New("other")))
+}
+
+// TestReadReplica проверяет, что чтение вне транзакции идёт в реплику, а запись и транзакции — в основную базу
+func TestReadReplica(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// снимок базы вместо отстающей реплики: новых посылок в нём нет
+	path := filepath.Join(t.TempDir(), "replica.db")
+	_, err = db.Exec("VACUUM INTO ?", path)
+	require.NoError(t, err)
+	replica, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer replica.Close()
+
+	store := NewParcelStoreWithConfig(db, StoreConfig{ReadReplica: replica})
+
+	// add
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+
+	// check
+	_, err = store.Get(id)
+	require.ErrorIs(t, err, ErrParcelNotFound)
+
+	err = store.WithTx(func(tx ParcelStore) error {
+		p, err := tx.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, id, p.Number)
+		return nil
+	})
+	require.NoError(t, err)
+
+	p, err := NewParcelStore(db).Get(id)
+	require.NoError(t, err)
+	require.Equal(t, id, p.Number)
+}
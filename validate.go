@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultMaxTextLen ограничение длины адреса и комментария по умолчанию (VARCHAR(512) в схеме)
+const DefaultMaxTextLen = 512
+
+var (
+	ErrAddressTooLong = errors.New("address is too long")
+	ErrNoteTooLong    = errors.New("note is too long")
+	ErrUnknownStatus  = errors.New("unknown parcel status")
+	// ErrInvalidCreatedAt CreatedAt не в формате RFC3339 или опережает время сервера больше допустимого
+	ErrInvalidCreatedAt = errors.New("invalid parcel creation time")
+	// ErrInvalidScheduledAt ScheduledAt не в формате RFC3339
+	ErrInvalidScheduledAt = errors.New("invalid parcel pickup time")
+	// ErrIncompleteParcel у посылки не заполнены обязательные поля, подробности в MissingFieldsError
+	ErrIncompleteParcel = errors.New("parcel is incomplete")
+	ErrParcelIsDraft    = errors.New("parcel is a draft")
+	ErrParcelNotDraft   = errors.New("parcel is not a draft")
+	// ErrInvalidParcel посылка не прошла Validate, все нарушения перечислены в ValidationErrors
+	ErrInvalidParcel = errors.New("invalid parcel")
+)
+
+// MissingFieldsError перечисляет незаполненные обязательные поля посылки.
+// errors.Is(err, ErrIncompleteParcel) для неё возвращает true.
+type MissingFieldsError struct {
+	Fields []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	return ErrIncompleteParcel.Error() + ", missing: " + strings.Join(e.Fields, ", ")
+}
+
+func (e *MissingFieldsError) Is(target error) bool {
+	return target == ErrIncompleteParcel
+}
+
+// FieldError нарушение в одном поле посылки.
+// Err — причина: ErrIncompleteParcel для незаполненного поля, ErrUnknownStatus для статуса.
+type FieldError struct {
+	Field   string
+	Message string
+	Err     error
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors все нарушения, найденные Validate, для ответа формы целиком.
+// errors.Is для неё возвращает true с ErrInvalidParcel и с причинами отдельных полей,
+// а незаполненные поля ещё и собраны в *MissingFieldsError для errors.As.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return ErrInvalidParcel.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e ValidationErrors) Is(target error) bool {
+	return target == ErrInvalidParcel
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e)+1)
+	var missing []string
+	for _, fe := range e {
+		errs = append(errs, fe)
+		if fe.Err == ErrIncompleteParcel {
+			missing = append(missing, fe.Field)
+		}
+	}
+	if len(missing) > 0 {
+		errs = append(errs, &MissingFieldsError{Fields: missing})
+	}
+
+	return errs
+}
+
+// Validate проверяет, что посылка заполнена полностью и её статус известен.
+// Возвращает ValidationErrors со всеми нарушениями сразу.
+func (p Parcel) Validate() error {
+	var errs ValidationErrors
+	missing := func(field string) {
+		errs = append(errs, FieldError{Field: field, Message: "is required", Err: ErrIncompleteParcel})
+	}
+
+	if p.Client == 0 {
+		missing("Client")
+	}
+	if strings.TrimSpace(p.Address) == "" && !p.AddressSet {
+		missing("Address")
+	}
+	if p.CreatedAt == "" {
+		missing("CreatedAt")
+	}
+	if !knownStatuses[p.Status] {
+		errs = append(errs, FieldError{Field: "Status", Message: fmt.Sprintf("unknown status %q", p.Status), Err: ErrUnknownStatus})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// LengthPolicy определяет, как обрабатывать слишком длинные строки
+type LengthPolicy int
+
+const (
+	// LengthPolicyReject отклоняет значение с ошибкой
+	LengthPolicyReject LengthPolicy = iota
+	// LengthPolicyTruncate молча обрезает значение до допустимой длины
+	LengthPolicyTruncate
+)
+
+// limitLength проверяет, что value не длиннее max символов.
+// Более длинное значение обрезается или отклоняется с ошибкой errTooLong в зависимости от policy.
+func limitLength(value string, max int, policy LengthPolicy, errTooLong error) (string, error) {
+	n := utf8.RuneCountInString(value)
+	if n <= max {
+		return value, nil
+	}
+
+	if policy == LengthPolicyTruncate {
+		return string([]rune(value)[:max]), nil
+	}
+
+	return value, fmt.Errorf("%w: %d characters, max %d", errTooLong, n, max)
+}
+
+// checkCreatedAt проверяет, что createdAt в формате RFC3339 и не опережает
+// текущее время больше чем на StoreConfig.MaxClockSkew, и возвращает его в UTC, как formatTime:
+// запросы по диапазону сравнивают created_at как строки, и смещение часового пояса их бы сломало.
+// Пустое значение не проверяется.
+func (s ParcelStore) checkCreatedAt(createdAt string) (string, error) {
+	if createdAt == "" {
+		return "", nil
+	}
+
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not RFC3339", ErrInvalidCreatedAt, createdAt)
+	}
+	if limit := s.cfg.Now().Add(s.cfg.MaxClockSkew); t.After(limit) {
+		return "", fmt.Errorf("%w: %s is ahead of server time by more than %s", ErrInvalidCreatedAt, createdAt, s.cfg.MaxClockSkew)
+	}
+
+	return formatTime(t), nil
+}
+
+// normalizeScheduledAt проверяет, что scheduledAt в формате RFC3339, и возвращает его в UTC:
+// AdvanceDuePickups сравнивает scheduled_at как строку. Пустое значение не проверяется.
+func normalizeScheduledAt(scheduledAt string) (string, error) {
+	if scheduledAt == "" {
+		return "", nil
+	}
+
+	t, err := time.Parse(time.RFC3339, scheduledAt)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not RFC3339", ErrInvalidScheduledAt, scheduledAt)
+	}
+
+	return formatTime(t), nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestAddressLengthLimit проверяет ограничение длины адреса и комментария
+func TestAddressLengthLimit(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	longAddress := strings.Repeat("д", 11)
+
+	// reject
+	store := NewParcelStoreWithConfig(db, StoreConfig{MaxAddressLen: 10, MaxNoteLen: 10})
+	parcel := getTestParcel()
+	parcel.Address = longAddress
+	_, err = store.Add(parcel)
+	require.ErrorIs(t, err, ErrAddressTooLong)
+
+	parcel = getTestParcel()
+	parcel.Note = longAddress
+	_, err = store.Add(parcel)
+	require.ErrorIs(t, err, ErrNoteTooLong)
+
+	id, err := store.Add(getTestParcel())
+	require.NoError(t, err)
+	require.ErrorIs(t, store.SetAddress(id, longAddress), ErrAddressTooLong)
+
+	// truncate
+	store = NewParcelStoreWithConfig(db, StoreConfig{MaxAddressLen: 10, LengthPolicy: LengthPolicyTruncate})
+	parcel = getTestParcel()
+	parcel.Address = longAddress
+	id, err = store.Add(parcel)
+	require.NoError(t, err)
+
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, longAddress[:len(longAddress)-len("д")], stored.Address)
+}
+
+// TestCreatedAtClockSkew проверяет отклонение посылок с CreatedAt из будущего
+func TestCreatedAtClockSkew(t *testing.T) {
+	// prepare
+	// посылки из будущего не должны оставаться в общей tracker.db
+	db := newTempStore(t).conn
+
+	now := time.Now()
+	store := NewParcelStoreWithConfig(db, StoreConfig{
+		MaxClockSkew: 5 * time.Minute,
+		Now:          func() time.Time { return now },
+	})
+
+	// add
+	parcel := getTestParcel()
+	parcel.CreatedAt = formatTime(now.Add(time.Minute))
+	_, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	parcel.CreatedAt = formatTime(now.Add(time.Hour))
+	_, err = store.Add(parcel)
+	require.ErrorIs(t, err, ErrInvalidCreatedAt)
+
+	parcel.CreatedAt = "вчера"
+	_, err = store.Add(parcel)
+	require.ErrorIs(t, err, ErrInvalidCreatedAt)
+
+	// list
+	// строка с CreatedAt из будущего, записанная в обход Add
+	res, err := db.Exec("INSERT INTO parcel (client, status, address, created_at) VALUES (?, ?, ?, ?)",
+		1000, ParcelStatusRegistered, "test", formatTime(now.Add(24*time.Hour)))
+	require.NoError(t, err)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+
+	parcels, err := store.ListFutureDated()
+	require.NoError(t, err)
+
+	var found bool
+	for _, p := range parcels {
+		found = found || p.Number == id
+	}
+	require.True(t, found)
+}
+
+// TestValidateAllErrors проверяет, что Validate сообщает обо всех нарушениях сразу
+func TestValidateAllErrors(t *testing.T) {
+	// prepare
+	parcel := Parcel{Status: "lost?", CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	// check
+	err := parcel.Validate()
+	require.ErrorIs(t, err, ErrInvalidParcel)
+	require.ErrorIs(t, err, ErrIncompleteParcel)
+	require.ErrorIs(t, err, ErrUnknownStatus)
+
+	var verrs ValidationErrors
+	require.ErrorAs(t, err, &verrs)
+	fields := make([]string, len(verrs))
+	for i, fe := range verrs {
+		fields[i] = fe.Field
+	}
+	require.Equal(t, []string{"Client", "Address", "Status"}, fields)
+
+	var missing *MissingFieldsError
+	require.ErrorAs(t, err, &missing)
+	require.Equal(t, []string{"Client", "Address"}, missing.Fields)
+
+	require.NoError(t, getTestParcel().Validate())
+}
+
+// TestAddNormalizesTimes проверяет, что Add сохраняет CreatedAt и ScheduledAt в UTC
+func TestAddNormalizesTimes(t *testing.T) {
+	// prepare
+	db, err := sql.Open("sqlite", "tracker.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewParcelStore(db)
+
+	// add
+	parcel := getTestParcel()
+	parcel.CreatedAt = "2024-01-02T01:00:00+03:00"
+	parcel.ScheduledAt = "2024-01-03T10:00:00+03:00"
+	id, err := store.Add(parcel)
+	require.NoError(t, err)
+
+	// check
+	stored, err := store.Get(id)
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-01T22:00:00Z", stored.CreatedAt)
+	require.Equal(t, "2024-01-03T07:00:00Z", stored.ScheduledAt)
+
+	parcel.ScheduledAt = "завтра утром"
+	_, err = store.Add(parcel)
+	require.ErrorIs(t, err, ErrInvalidScheduledAt)
+}